@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// autosaveIntervals are the settings choices; 0 turns autosave off
+var autosaveIntervals = []time.Duration{
+	0,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	2 * time.Minute,
+}
+
+const defaultAutosaveInterval = 10 * time.Second
+
+// autosaveMsg fires one interval after a change. It carries the revision
+// it was scheduled for, so only the timer of the latest change saves and a
+// burst of edits results in a single write.
+type autosaveMsg struct {
+	revision int
+}
+
+func autosaveAfter(interval time.Duration, revision int) tea.Cmd {
+	return tea.Tick(interval, func(_ time.Time) tea.Msg {
+		return autosaveMsg{revision: revision}
+	})
+}
+
+func (m model) revision() int {
+	if m.notebook == nil {
+		return 0
+	}
+	return m.notebook.Revision()
+}
+
+// scheduleAutosave starts a debounce timer if the notebook changed since
+// before
+func (m model) scheduleAutosave(before int, cmd tea.Cmd) (tea.Model, tea.Cmd) {
+	if m.notebook == nil || m.notebook.autosaveInterval == 0 || m.revision() == before || !m.notebook.IsDirty() {
+		return m, cmd
+	}
+	return m, tea.Batch(cmd, autosaveAfter(m.notebook.autosaveInterval, m.revision()))
+}
+
+func (m model) handleAutosave(msg autosaveMsg) (tea.Model, tea.Cmd) {
+	if m.notebook == nil || m.notebook.autosaveInterval == 0 ||
+		msg.revision != m.revision() || !m.notebook.IsDirty() {
+		return m, nil
+	}
+	if err := m.notebook.Save(); err != nil {
+		m.err = fmt.Errorf("autosave: %w", err)
+	}
+	return m, nil
+}
+
+func autosaveText(interval time.Duration) string {
+	if interval == 0 {
+		return "Off"
+	}
+	return fmt.Sprintf("%s after the last change", interval)
+}
+
+// SetAutosaveInterval changes how long after a change the notebook is saved
+// automatically. It is saved with the notebook.
+func (n *Notebook) SetAutosaveInterval(interval time.Duration) {
+	n.autosaveInterval = interval
+	n.markDirty()
+}
+
+func nextAutosaveInterval(current time.Duration) time.Duration {
+	for i, interval := range autosaveIntervals {
+		if interval == current {
+			return autosaveIntervals[(i+1)%len(autosaveIntervals)]
+		}
+	}
+	return autosaveIntervals[0]
+}
+
+// === QUIT CONFIRMATION ===
+
+// quit exits right away unless there are unsaved changes, in which case it
+// asks first
+func (m model) quit() (tea.Model, tea.Cmd) {
+	if m.notebook != nil && m.notebook.IsDirty() {
+		m.confirmQuit = true
+		return m, nil
+	}
+	return m, tea.Quit
+}
+
+func (m model) updateQuitDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "s", "enter":
+		if err := m.notebook.Save(); err != nil {
+			m.confirmQuit = false
+			m.err = err
+			return m, nil
+		}
+		return m, tea.Quit
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "n":
+		m.confirmQuit = false
+	}
+	return m, nil
+}
+
+func (m model) viewQuitDialog() string {
+	var b strings.Builder
+
+	b.WriteString(warningStyle.Render("● Unsaved changes"))
+	b.WriteString("\n\n")
+	b.WriteString(lipgloss.NewStyle().Foreground(text).Render(
+		"Your notebook has changes that haven't been saved.\nWhat do you want to do?"))
+	b.WriteString("\n\n")
+	b.WriteString(renderHelp(
+		"s", "Save and quit",
+		"q", "Quit without saving",
+		"Esc", "Cancel",
+	))
+
+	dialog := glowBoxStyle.
+		BorderForeground(warning).
+		Width(min(60, m.boxWidth())).
+		Render(b.String())
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		dialog)
+}
+
+// renderHeader adds the unsaved-changes indicator to the shared header
+func (m model) renderHeader(title string, subtitle string) string {
+	if m.notebook != nil && m.notebook.IsDirty() {
+		subtitle += "  " + warningStyle.Render("● unsaved")
+	}
+	return renderHeader(title, subtitle, m.layoutWidth(), m.compactHeader())
+}
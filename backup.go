@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backup generations are the previous versions of the notebook file, kept
+// as-is (still encrypted) in <notebook>.backups/ and named after the time
+// they were replaced.
+const backupTimeFormat = "20060102-150405.000000"
+
+// retentionPolicy decides which backup generations survive a save. A
+// generation is kept if any of the rules wants it.
+type retentionPolicy struct {
+	KeepLast   int // newest generations, always kept
+	KeepDaily  int // newest generation of each of the last N days
+	KeepWeekly int // newest generation of each of the last N weeks
+}
+
+var defaultRetention = retentionPolicy{KeepLast: 5, KeepDaily: 7, KeepWeekly: 4}
+
+// retentionPresets are the choices offered on the settings screen
+var retentionPresets = []retentionPolicy{
+	{KeepLast: 3},
+	defaultRetention,
+	{KeepLast: 10, KeepDaily: 14, KeepWeekly: 12},
+}
+
+func (p retentionPolicy) String() string {
+	parts := []string{fmt.Sprintf("last %d", p.KeepLast)}
+	if p.KeepDaily > 0 {
+		parts = append(parts, fmt.Sprintf("daily for %d days", p.KeepDaily))
+	}
+	if p.KeepWeekly > 0 {
+		parts = append(parts, fmt.Sprintf("weekly for %d weeks", p.KeepWeekly))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type backupGeneration struct {
+	Path  string
+	Time  time.Time
+	Notes int
+	Err   error // set when the generation couldn't be decrypted
+}
+
+func backupDir(filename string) string {
+	return filename + ".backups"
+}
+
+// backupCurrent keeps the notebook file that is about to be replaced as a
+// new generation. It returns the backup path, or "" if there was nothing to
+// back up yet.
+func (n *Notebook) backupCurrent() (string, error) {
+	if _, err := os.Stat(n.filename); errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+
+	dir := backupDir(n.filename)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(n.filename), filepath.Ext(n.filename))
+	backup := filepath.Join(dir, fmt.Sprintf("%s-%s.alpaka", base, time.Now().Format(backupTimeFormat)))
+
+	// The live file is replaced by a rename, so a hard link keeps the old
+	// contents without copying. Fall back to a copy where links don't work.
+	if err := os.Link(n.filename, backup); err != nil {
+		if err := copyFile(n.filename, backup); err != nil {
+			return "", err
+		}
+	}
+
+	return backup, nil
+}
+
+// backupPaths returns the generations of the notebook, newest first
+func (n *Notebook) backupPaths() ([]backupGeneration, error) {
+	dir := backupDir(n.filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(n.filename), filepath.Ext(n.filename))
+	var generations []backupGeneration
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"-"), ".alpaka")
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		generations = append(generations, backupGeneration{
+			Path: filepath.Join(dir, name),
+			Time: t,
+		})
+	}
+
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i].Time.After(generations[j].Time)
+	})
+
+	return generations, nil
+}
+
+// ListBackups decrypts every generation with the notebook's password to
+// report how many notes it holds. Generations written under a different
+// password are listed with Err set.
+func (n *Notebook) ListBackups() ([]backupGeneration, error) {
+	generations, err := n.backupPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range generations {
+		backup, _, err := readNotebook(generations[i].Path, n.password, n)
+		if err != nil {
+			generations[i].Err = err
+			continue
+		}
+		generations[i].Notes = len(backup.Notes)
+	}
+
+	return generations, nil
+}
+
+// RestoreBackup replaces the notes and the trash with those of a backup
+// generation and saves, so the state being replaced becomes a generation
+// of its own. The trash is restored too so no note ends up in both.
+func (n *Notebook) RestoreBackup(path string) error {
+	backup, _, err := readNotebook(path, n.password, n)
+	if err != nil {
+		return err
+	}
+
+	previousNotes, previousTrash, previousRevision := n.Notes, n.Trash, n.revision
+	n.Notes, n.Trash = backup.Notes, backup.Trash
+	n.markDirty()
+	if err := n.Save(); err != nil {
+		n.Notes, n.Trash, n.revision = previousNotes, previousTrash, previousRevision
+		return err
+	}
+	n.index = newSearchIndex(n.Notes)
+	return nil
+}
+
+// rekeyBackups re-encrypts the generations that open with oldPassword
+// under the notebook's current key and deletes the rest, which could only
+// be read with some older password. old holds the previous key so it
+// isn't derived again for every generation.
+func (n *Notebook) rekeyBackups(old *Notebook, oldPassword string) (rekeyed, removed int, err error) {
+	generations, err := n.backupPaths()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, generation := range generations {
+		backup, _, err := readNotebook(generation.Path, oldPassword, old)
+		if err == nil {
+			backup.password, backup.salt, backup.kdf, backup.key = n.password, n.salt, n.kdf, n.key
+			var data []byte
+			if data, err = backup.encode(); err == nil {
+				err = writeFileAtomic(generation.Path, data)
+			}
+			if err == nil {
+				rekeyed++
+				continue
+			}
+		}
+		if err := os.Remove(generation.Path); err != nil {
+			return rekeyed, removed, err
+		}
+		removed++
+	}
+	return rekeyed, removed, nil
+}
+
+// rekeySummary describes what ChangePassword did to the backups, or ""
+// if there were none
+func rekeySummary(rekeyed, removed int) string {
+	var parts []string
+	if rekeyed > 0 {
+		parts = append(parts, fmt.Sprintf("%d backups re-encrypted", rekeyed))
+	}
+	if removed > 0 {
+		parts = append(parts, fmt.Sprintf("%d not under the old password deleted", removed))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SetRetention changes which backup generations are kept. It is saved
+// with the notebook.
+func (n *Notebook) SetRetention(retention retentionPolicy) {
+	n.retention = retention
+	n.markDirty()
+}
+
+// pruneBackups deletes the generations the retention policy doesn't keep
+func (n *Notebook) pruneBackups(now time.Time) error {
+	generations, err := n.backupPaths()
+	if err != nil {
+		return err
+	}
+
+	keep := n.retention.keep(generations, now)
+	for i, generation := range generations {
+		if keep[i] {
+			continue
+		}
+		if err := os.Remove(generation.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keep marks which of the generations (sorted newest first) to retain
+func (p retentionPolicy) keep(generations []backupGeneration, now time.Time) []bool {
+	keep := make([]bool, len(generations))
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+
+	dailyCutoff := now.AddDate(0, 0, -p.KeepDaily)
+	weeklyCutoff := now.AddDate(0, 0, -7*p.KeepWeekly)
+
+	for i, generation := range generations {
+		if i < p.KeepLast {
+			keep[i] = true
+		}
+
+		day := generation.Time.Format("2006-01-02")
+		if generation.Time.After(dailyCutoff) && !days[day] {
+			days[day] = true
+			keep[i] = true
+		}
+
+		year, week := generation.Time.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if generation.Time.After(weeklyCutoff) && !weeks[weekKey] {
+			weeks[weekKey] = true
+			keep[i] = true
+		}
+	}
+
+	return keep
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, defaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
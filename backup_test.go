@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetentionKeep(t *testing.T) {
+	// A Sunday, the last day of ISO week 11
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	at := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 0, 0, 0, time.Local) }
+
+	tests := []struct {
+		name   string
+		policy retentionPolicy
+		times  []time.Time // newest first
+		want   []bool
+	}{
+		{"nothing to keep", defaultRetention, nil, []bool{}},
+		{"last only", retentionPolicy{KeepLast: 3},
+			[]time.Time{at(15, 11), at(15, 10), at(14, 9), at(13, 9), at(1, 9)},
+			[]bool{true, true, true, false, false}},
+		{"newest of each day", retentionPolicy{KeepDaily: 2},
+			[]time.Time{at(15, 11), at(15, 10), at(14, 11), at(14, 9), at(13, 11)},
+			[]bool{true, false, true, false, false}},
+		{"newest of each week", retentionPolicy{KeepWeekly: 2},
+			[]time.Time{at(14, 9), at(10, 9), at(5, 9), at(1, 9)},
+			[]bool{true, false, true, false}},
+		{"rules add up", retentionPolicy{KeepLast: 1, KeepDaily: 1, KeepWeekly: 2},
+			[]time.Time{at(15, 11), at(15, 10), at(14, 9), at(5, 9), at(4, 9)},
+			[]bool{true, false, false, true, false}},
+	}
+
+	for _, tt := range tests {
+		generations := make([]backupGeneration, len(tt.times))
+		for i, when := range tt.times {
+			generations[i].Time = when
+		}
+		if got := tt.policy.keep(generations, now); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: keep = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.alpaka")
+	notebook := NewNotebook(path, "secret")
+	notebook.SetRetention(retentionPolicy{KeepLast: 2})
+	for i := 1; i <= 4; i++ {
+		notebook.AddNote(NewNote(fmt.Sprintf("Note %d", i), "", nil))
+		if err := notebook.Save(); err != nil {
+			t.Fatalf("Save %d: %v", i, err)
+		}
+	}
+
+	// Saves 2 to 4 each kept the file before them; pruning leaves two
+	generations, err := notebook.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counts []int
+	for _, generation := range generations {
+		if generation.Err != nil {
+			t.Fatalf("%s: %v", generation.Path, generation.Err)
+		}
+		counts = append(counts, generation.Notes)
+	}
+	if want := []int{3, 2}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("generations hold %v notes, want %v", counts, want)
+	}
+
+	// Restoring brings back the notes and the trash of the generation
+	notebook.DeleteNote(notebook.Notes[0].ID)
+	if err := notebook.RestoreBackup(generations[1].Path); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	if len(notebook.Notes) != 2 || len(notebook.Trash) != 0 {
+		t.Errorf("restored %d notes and %d in the trash, want 2 and 0", len(notebook.Notes), len(notebook.Trash))
+	}
+	if results, _ := notebook.Search("title:note"); len(results) != 2 {
+		t.Errorf("search after restoring found %d notes, want 2", len(results))
+	}
+	if notebook.IsDirty() {
+		t.Error("notebook is dirty after restoring")
+	}
+
+	reloaded, err := LoadNotebook(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.Notes) != 2 || len(reloaded.Trash) != 0 || reloaded.retention.KeepLast != 2 {
+		t.Errorf("reloaded %d notes, %d in the trash, retention %v", len(reloaded.Notes), len(reloaded.Trash), reloaded.retention)
+	}
+
+	// The state replaced by the restore became the newest generation
+	generations, _ = notebook.ListBackups()
+	if len(generations) != 2 || generations[0].Notes != 4 {
+		t.Errorf("after restoring, generations = %+v", generations)
+	}
+}
+
+func TestChangePasswordRekeysBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.alpaka")
+	notebook := NewNotebook(path, "old")
+	for i := 0; i < 3; i++ {
+		notebook.AddNote(NewNote("Note", "", nil))
+		if err := notebook.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A generation from some earlier password can't be re-encrypted
+	other := NewNotebook(filepath.Join(t.TempDir(), "other.alpaka"), "older")
+	if err := other.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(other.filename)
+	stale := filepath.Join(backupDir(path), "notes-20200101-000000.000000.alpaka")
+	if err := os.WriteFile(stale, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rekeyed, removed, err := notebook.ChangePassword("old", "new")
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if rekeyed != 2 || removed != 1 {
+		t.Errorf("re-encrypted %d and removed %d backups, want 2 and 1", rekeyed, removed)
+	}
+
+	generations, _ := notebook.backupPaths()
+	if len(generations) != 2 {
+		t.Fatalf("%d generations after changing the password, want 2", len(generations))
+	}
+	for _, generation := range generations {
+		if _, _, err := readNotebook(generation.Path, "old", nil); err == nil {
+			t.Errorf("%s still opens with the old password", generation.Path)
+		}
+		if _, _, err := readNotebook(generation.Path, "new", nil); err != nil {
+			t.Errorf("%s doesn't open with the new password: %v", generation.Path, err)
+		}
+	}
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// passwordEnv names the environment variable the subcommands read the
+// notebook password from when --password-fd isn't given.
+const passwordEnv = "ALPAKA_PASSWORD"
+
+const cliUsage = `Usage: alpaka [--file PATH] [command] [flags]
+
+Without a command the interactive app is started.
+
+Commands:
+  add      --title T [--tags "a b"]   add a note, content is read from stdin
+  list                                list notes
+  show     <id>                       print a note
+  search   [flags] <query>            print notes matching a query
+  delete   <id>                       move a note to the trash
+  export                              print every note as Markdown
+  passwd                              change the notebook password
+
+Common flags:
+  --file PATH        notebook file (default $ALPAKA_NOTEBOOK, or
+                     notatki.alpaka in $XDG_DATA_HOME/alpaka)
+  --json             print JSON instead of text
+  --password-fd N    read the password from file descriptor N
+
+Search queries combine words, "phrases" and filters with AND, OR, NOT
+(or -) and parentheses, e.g.:
+  alpaka search 'tag:work -tag:archived (title:"release notes" OR words>200)'
+Filters: title: content: tag: before:YYYY-MM-DD after:YYYY-MM-DD words>N
+Flags go before the query: everything from its first word on is query
+text, so words may start with "-". "--" ends the flags, e.g.
+  alpaka search --json -- -tag:archived
+
+The password is read from --password-fd, then $ALPAKA_PASSWORD, then
+prompted for. Notes can be given by a unique prefix of their ID.
+`
+
+// runCommand runs a non-interactive subcommand instead of the TUI
+func runCommand(name string, args []string) error {
+	switch name {
+	case "add":
+		return runAdd(args)
+	case "list", "ls":
+		return runList(args)
+	case "show":
+		return runShow(args)
+	case "search":
+		return runSearch(args)
+	case "delete", "rm":
+		return runDelete(args)
+	case "export":
+		return runExport(args)
+	case "passwd":
+		return runPasswd(args)
+	case "help":
+		fmt.Print(cliUsage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q (see alpaka help)", name)
+	}
+}
+
+// notebookFlags are the flags shared by the subcommands that open a notebook
+type notebookFlags struct {
+	*flag.FlagSet
+	file       *string
+	passwordFD *int
+	json       *bool
+}
+
+func newNotebookFlags(name string) notebookFlags {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	return notebookFlags{
+		FlagSet:    flags,
+		file:       flags.String("file", defaultNotebookPath(), "notebook file"),
+		passwordFD: flags.Int("password-fd", -1, "read the password from this file descriptor"),
+		json:       flags.Bool("json", false, "print JSON"),
+	}
+}
+
+// parse accepts flags before and after the positional arguments, so both
+// "show --json ab12" and "show ab12 --json" work. Everything after "--" is
+// positional, and so is anything that looks like a flag but isn't one.
+func (f notebookFlags) parse(args []string) ([]string, error) {
+	return f.parseArgs(args, false)
+}
+
+// parseWords is parse for commands that take free text, such as a search
+// query: after the first positional argument the rest is all text, so
+// "search release -tag:archived" keeps its "-tag:archived".
+func (f notebookFlags) parseWords(args []string) ([]string, error) {
+	return f.parseArgs(args, true)
+}
+
+func (f notebookFlags) parseArgs(args []string, words bool) ([]string, error) {
+	var positional []string
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		if words && len(positional) > 0 {
+			return append(positional, args...), nil
+		}
+
+		name, hasValue := flagName(arg)
+		defined := f.Lookup(name)
+		if name == "" || defined == nil && name != "h" && name != "help" {
+			positional = append(positional, arg)
+			args = args[1:]
+			continue
+		}
+
+		// Hand the flag package this one flag, with its value if that is
+		// the next argument
+		n := 1
+		if defined != nil && !hasValue && !isBoolFlag(defined) {
+			n = min(2, len(args))
+		}
+		if err := f.Parse(args[:n]); err != nil {
+			return nil, err
+		}
+		args = args[n:]
+	}
+	return positional, nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	boolean, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && boolean.IsBoolFlag()
+}
+
+// flagName is the name of the flag arg sets, or "" if it isn't a flag
+func flagName(arg string) (name string, hasValue bool) {
+	if len(arg) < 2 || arg[0] != '-' {
+		return "", false
+	}
+	name = strings.TrimPrefix(arg[1:], "-")
+	if name == "" || name[0] == '-' || name[0] == '=' {
+		return "", false
+	}
+	name, _, hasValue = strings.Cut(name, "=")
+	return name, hasValue
+}
+
+func (f notebookFlags) open() (*Notebook, error) {
+	password, err := commandPassword(*f.passwordFD, "Password: ")
+	if err != nil {
+		return nil, err
+	}
+
+	notebook, err := LoadNotebook(*f.file, password)
+	if errors.Is(err, ErrNotExist) {
+		return nil, fmt.Errorf("%s does not exist, create it by starting alpaka without a command", *f.file)
+	}
+	return notebook, err
+}
+
+// commandPassword takes the password from the file descriptor if one was
+// given, then from $ALPAKA_PASSWORD, and only then prompts.
+func commandPassword(fd int, prompt string) (string, error) {
+	if fd >= 0 {
+		file := os.NewFile(uintptr(fd), "password-fd")
+		if file == nil {
+			return "", fmt.Errorf("invalid password file descriptor %d", fd)
+		}
+		defer file.Close()
+
+		line, err := bufio.NewReader(file).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("reading password from fd %d: %w", fd, err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	if password, ok := os.LookupEnv(passwordEnv); ok {
+		return password, nil
+	}
+
+	return readPassword(prompt)
+}
+
+// alpaka add --title T [--tags "a b"] < content
+func runAdd(args []string) error {
+	flags := newNotebookFlags("add")
+	title := flags.String("title", "", "note title")
+	tags := flags.String("tags", "", "space or comma separated tags")
+	if _, err := flags.parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*title) == "" {
+		return fmt.Errorf("add needs a --title")
+	}
+
+	// Open first, so a prompted password isn't mistaken for content
+	notebook, err := flags.open()
+	if err != nil {
+		return err
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "Enter the content, end with Ctrl+D:")
+	}
+	content, err := io.ReadAll(stdinReader)
+	if err != nil {
+		return err
+	}
+
+	note := NewNote(*title, strings.TrimRight(string(content), "\n"), splitTags(*tags))
+	notebook.AddNote(note)
+	if err := notebook.Save(); err != nil {
+		return err
+	}
+
+	if *flags.json {
+		return printJSON(newCLINote(*note, true))
+	}
+	fmt.Println(note.ID)
+	return nil
+}
+
+// alpaka list
+func runList(args []string) error {
+	flags := newNotebookFlags("list")
+	if _, err := flags.parse(args); err != nil {
+		return err
+	}
+	notebook, err := flags.open()
+	if err != nil {
+		return err
+	}
+
+	return printNotes(notebook.GetSortedNotes(sortByDate), *flags.json, false)
+}
+
+// alpaka show <id>
+func runShow(args []string) error {
+	flags := newNotebookFlags("show")
+	positional, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("show needs exactly one note ID")
+	}
+	notebook, err := flags.open()
+	if err != nil {
+		return err
+	}
+
+	note, err := findNote(notebook, positional[0])
+	if err != nil {
+		return err
+	}
+
+	if *flags.json {
+		return printJSON(newCLINote(note, true))
+	}
+	printNote(note)
+	return nil
+}
+
+// alpaka search <query>
+func runSearch(args []string) error {
+	flags := newNotebookFlags("search")
+	positional, err := flags.parseWords(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("search needs a query")
+	}
+	// Check the query before asking for the password
+	query := strings.Join(positional, " ")
+	if _, err := parseQuery(query); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	notebook, err := flags.open()
+	if err != nil {
+		return err
+	}
+
+	results, err := notebook.Search(query)
+	if err != nil {
+		return err
+	}
+	return printSearchResults(results, *flags.json)
+}
+
+// alpaka delete <id>
+func runDelete(args []string) error {
+	flags := newNotebookFlags("delete")
+	positional, err := flags.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("delete needs exactly one note ID")
+	}
+	notebook, err := flags.open()
+	if err != nil {
+		return err
+	}
+
+	note, err := findNote(notebook, positional[0])
+	if err != nil {
+		return err
+	}
+	notebook.DeleteNote(note.ID)
+	if err := notebook.Save(); err != nil {
+		return err
+	}
+
+	if *flags.json {
+		return printJSON(newCLINote(note, false))
+	}
+	fmt.Printf("Moved %q to the trash\n", note.Title)
+	return nil
+}
+
+// alpaka export
+func runExport(args []string) error {
+	flags := newNotebookFlags("export")
+	if _, err := flags.parse(args); err != nil {
+		return err
+	}
+	notebook, err := flags.open()
+	if err != nil {
+		return err
+	}
+
+	notes := notebook.GetSortedNotes(sortByDate)
+	if *flags.json {
+		return printNotes(notes, true, true)
+	}
+
+	for i, note := range notes {
+		if i > 0 {
+			fmt.Print("\n---\n\n")
+		}
+		fmt.Printf("# %s\n\n", note.Title)
+		if len(note.Tags) > 0 {
+			fmt.Printf("Tags: %s  \n", strings.Join(note.Tags, ", "))
+		}
+		fmt.Printf("Created: %s  \n", note.Created.Format("2006-01-02 15:04"))
+		fmt.Printf("Modified: %s\n\n", note.Modified.Format("2006-01-02 15:04"))
+		fmt.Println(note.Content)
+	}
+	return nil
+}
+
+// alpaka passwd [--file notebook.alpaka]
+func runPasswd(args []string) error {
+	flags := newNotebookFlags("passwd")
+	if _, err := flags.parse(args); err != nil {
+		return err
+	}
+
+	oldPassword, err := commandPassword(*flags.passwordFD, "Current password: ")
+	if err != nil {
+		return err
+	}
+	notebook, err := LoadNotebook(*flags.file, oldPassword)
+	if err != nil {
+		return err
+	}
+
+	newPassword, err := readPassword("New password: ")
+	if err != nil {
+		return err
+	}
+	confirm, err := readPassword("Confirm new password: ")
+	if err != nil {
+		return err
+	}
+	if newPassword != confirm {
+		return fmt.Errorf("new passwords do not match")
+	}
+
+	rekeyed, removed, err := notebook.ChangePassword(oldPassword, newPassword)
+	if err != nil && !errors.Is(err, ErrOldBackups) {
+		return err
+	}
+
+	fmt.Printf("Password changed, %s re-encrypted (%d notes)\n", *flags.file, len(notebook.Notes))
+	if summary := rekeySummary(rekeyed, removed); summary != "" {
+		fmt.Printf("Backups: %s\n", summary)
+	}
+	return err
+}
+
+// findNote looks a note up by its ID or a unique prefix of it
+func findNote(notebook *Notebook, id string) (Note, error) {
+	if note, ok := notebook.GetNote(id); ok {
+		return note, nil
+	}
+
+	var matches []Note
+	for _, note := range notebook.Notes {
+		if strings.HasPrefix(note.ID, id) {
+			matches = append(matches, note)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return Note{}, fmt.Errorf("no note with ID %q", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return Note{}, fmt.Errorf("ID %q is ambiguous, it matches %d notes", id, len(matches))
+	}
+}
+
+func splitTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// cliNote is the JSON form of a note printed by the subcommands. It leaves
+// out the revision history, which is only meaningful inside the app.
+type cliNote struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content,omitempty"`
+	Tags     []string  `json:"tags"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+	Pinned   bool      `json:"pinned"`
+	Score    float64   `json:"score,omitempty"` // search relevance
+}
+
+func newCLINote(note Note, withContent bool) cliNote {
+	out := cliNote{
+		ID:       note.ID,
+		Title:    note.Title,
+		Tags:     note.Tags,
+		Created:  note.Created,
+		Modified: note.Modified,
+		Pinned:   note.Pinned,
+	}
+	if out.Tags == nil {
+		out.Tags = []string{}
+	}
+	if withContent {
+		out.Content = note.Content
+	}
+	return out
+}
+
+// printNotes prints one line per note, or a JSON array
+func printNotes(notes []Note, asJSON, withContent bool) error {
+	if asJSON {
+		out := make([]cliNote, 0, len(notes))
+		for _, note := range notes {
+			out = append(out, newCLINote(note, withContent))
+		}
+		return printJSON(out)
+	}
+
+	for _, note := range notes {
+		fmt.Println(noteLine(note))
+	}
+	return nil
+}
+
+// printSearchResults is printNotes with the relevance of each note
+func printSearchResults(results []searchResult, asJSON bool) error {
+	if asJSON {
+		out := make([]cliNote, 0, len(results))
+		for _, result := range results {
+			note := newCLINote(result.Note, false)
+			note.Score = result.Score
+			out = append(out, note)
+		}
+		return printJSON(out)
+	}
+
+	for _, result := range results {
+		fmt.Printf("%6.2f  %s\n", result.Score, noteLine(result.Note))
+	}
+	return nil
+}
+
+// noteLine is the one-line summary of a note the listings print
+func noteLine(note Note) string {
+	pin := " "
+	if note.Pinned {
+		pin = "*"
+	}
+	// IDs from hand-edited or imported files can be shorter
+	id := note.ID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	line := fmt.Sprintf("%-8s %s %s  %s", id, pin, note.Modified.Format("2006-01-02 15:04"), note.Title)
+	if len(note.Tags) > 0 {
+		line += "  #" + strings.Join(note.Tags, " #")
+	}
+	return line
+}
+
+func printNote(note Note) {
+	fmt.Printf("ID:       %s\n", note.ID)
+	fmt.Printf("Title:    %s\n", note.Title)
+	if len(note.Tags) > 0 {
+		fmt.Printf("Tags:     %s\n", strings.Join(note.Tags, " "))
+	}
+	fmt.Printf("Created:  %s\n", note.Created.Format("2006-01-02 15:04"))
+	fmt.Printf("Modified: %s\n", note.Modified.Format("2006-01-02 15:04"))
+	fmt.Printf("\n%s\n", note.Content)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readPassword prompts on stderr and reads without echo from a terminal,
+// or a plain line when stdin is piped.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
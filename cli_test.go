@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		words    bool
+		want     []string
+		wantFile string
+		wantJSON bool
+		wantErr  bool
+	}{
+		{[]string{"ab12", "--json"}, false, []string{"ab12"}, "", true, false},
+		{[]string{"--json", "ab12"}, false, []string{"ab12"}, "", true, false},
+		{[]string{"--file", "x.alpaka", "ab12"}, false, []string{"ab12"}, "x.alpaka", false, false},
+		{[]string{"-file=x.alpaka", "ab12"}, false, []string{"ab12"}, "x.alpaka", false, false},
+		{[]string{"--json=false", "ab12"}, false, []string{"ab12"}, "", false, false},
+		{[]string{"--", "--json"}, false, []string{"--json"}, "", false, false},
+		{[]string{"-", "--bogus"}, false, []string{"-", "--bogus"}, "", false, false},
+		{[]string{"--password-fd", "three"}, false, nil, "", false, true},
+		{[]string{"--file"}, false, nil, "", false, true},
+
+		// In words mode the flags end at the first word of the query
+		{[]string{"--json", "release", "--file", "x"}, true, []string{"release", "--file", "x"}, "", true, false},
+		{[]string{"release", "-tag:archived"}, true, []string{"release", "-tag:archived"}, "", false, false},
+		{[]string{"-tag:archived", "release"}, true, []string{"-tag:archived", "release"}, "", false, false},
+		{[]string{"--json", "--", "--json"}, true, []string{"--json"}, "", true, false},
+	}
+
+	for _, tt := range tests {
+		flags := newNotebookFlags("test")
+		flags.SetOutput(io.Discard)
+		got, err := flags.parseArgs(tt.args, tt.words)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseArgs(%q) error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		wantFile := tt.wantFile
+		if wantFile == "" {
+			wantFile = defaultNotebookPath()
+		}
+		if !reflect.DeepEqual(got, tt.want) || *flags.file != wantFile || *flags.json != tt.wantJSON {
+			t.Errorf("parseArgs(%q) = %q, file %q, json %v; want %q, %q, %v",
+				tt.args, got, *flags.file, *flags.json, tt.want, wantFile, tt.wantJSON)
+		}
+	}
+}
+
+func TestCommandPassword(t *testing.T) {
+	t.Setenv(passwordEnv, "from env")
+	if password, err := commandPassword(-1, ""); err != nil || password != "from env" {
+		t.Errorf("with $%s set: %q, %v", passwordEnv, password, err)
+	}
+
+	// A file descriptor wins over the environment and only its first line counts
+	tests := []struct {
+		contents string
+		want     string
+	}{
+		{"secret\nsecond line\n", "secret"},
+		{"windows\r\n", "windows"},
+		{"no newline", "no newline"},
+		{"\n", ""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "password")
+		if err := os.WriteFile(path, []byte(tt.contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// commandPassword closes the descriptor itself
+		password, err := commandPassword(int(file.Fd()), "")
+		if err != nil || password != tt.want {
+			t.Errorf("reading %q: %q, %v; want %q", tt.contents, password, err, tt.want)
+		}
+	}
+}
+
+func TestNoteLine(t *testing.T) {
+	modified := time.Date(2026, 3, 15, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		note Note
+		want string
+	}{
+		{Note{ID: "0123456789abcdef", Title: "Shopping", Modified: modified},
+			"01234567   2026-03-15 12:30  Shopping"},
+		{Note{ID: "ab", Title: "Short ID", Modified: modified, Pinned: true, Tags: []string{"home", "todo"}},
+			"ab       * 2026-03-15 12:30  Short ID  #home #todo"},
+	}
+	for _, tt := range tests {
+		if got := noteLine(tt.note); got != tt.want {
+			t.Errorf("noteLine = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSplitTags(t *testing.T) {
+	got := splitTags("home, work\ttodo,,")
+	if want := []string{"home", "work", "todo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("splitTags = %q, want %q", got, want)
+	}
+	if got := splitTags(" , "); len(got) != 0 {
+		t.Errorf("splitTags of separators only = %q", got)
+	}
+}
+
+func TestFindNote(t *testing.T) {
+	notebook := NewNotebook("", "")
+	notebook.Notes = []Note{{ID: "ab12cd"}, {ID: "ab34ef"}, {ID: "ff00aa"}}
+
+	tests := []struct {
+		id, want, wantErr string
+	}{
+		{"ab12cd", "ab12cd", ""},
+		{"ff", "ff00aa", ""},
+		{"ab", "", "ambiguous"},
+		{"zz", "", "no note"},
+	}
+	for _, tt := range tests {
+		note, err := findNote(notebook, tt.id)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("findNote(%q) error = %v, want %q", tt.id, err, tt.wantErr)
+			}
+		} else if err != nil || note.ID != tt.want {
+			t.Errorf("findNote(%q) = %q, %v; want %q", tt.id, note.ID, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	saltSize = 16
+	keySize  = 32 // AES-256
+)
+
+// kdfParams are the Argon2id cost parameters. They are stored in the file
+// header so they can be raised later without breaking older notebooks.
+type kdfParams struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+var defaultKDFParams = kdfParams{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// Limits on the parameters read from a file. The header is checked only
+// after the key has been derived, so a crafted file could otherwise make
+// Argon2id allocate any amount of memory. Threads is a uint8, so it can't
+// go above 255 to begin with.
+const (
+	maxKDFTime   = 10
+	maxKDFMemory = 4 * 1024 * 1024 // KiB, 4 GiB
+)
+
+func (p kdfParams) String() string {
+	return fmt.Sprintf("t=%d,m=%d,p=%d", p.Time, p.Memory, p.Threads)
+}
+
+func parseKDFParams(s string) (kdfParams, error) {
+	var p kdfParams
+	if _, err := fmt.Sscanf(s, "t=%d,m=%d,p=%d", &p.Time, &p.Memory, &p.Threads); err != nil {
+		return p, fmt.Errorf("invalid kdf params %q", s)
+	}
+	if p.Time == 0 || p.Memory == 0 || p.Threads == 0 ||
+		p.Time > maxKDFTime || p.Memory > maxKDFMemory {
+		return p, fmt.Errorf("invalid kdf params %q", s)
+	}
+	return p, nil
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// deriveKey stretches the password into an AES-256 key with Argon2id
+func deriveKey(password string, salt []byte, p kdfParams) []byte {
+	return argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, keySize)
+}
+
+// passwordCheck is a verifier stored in the header so a wrong password can
+// be reported as such instead of as a damaged file. It is an HMAC keyed
+// with the derived key, so it reveals nothing cheaper to attack than the
+// key itself.
+func passwordCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("alpaka password check"))
+	return mac.Sum(nil)
+}
+
+// seal encrypts plaintext with AES-256-GCM and returns nonce||ciphertext.
+// additionalData is authenticated but not encrypted (we pass the file header).
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open reverses seal. Any modification of the ciphertext or the
+// additional data makes it fail.
+func open(key, data, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// === LEGACY (VERSION:1.0) ===
+
+// legacyDecrypt undoes the repeating-key XOR used by 1.0 files. It is only
+// kept so old notebooks can be read and migrated.
+func legacyDecrypt(data []byte, password string) []byte {
+	key := legacyDeriveKey(password, len(data)+256)
+	result := make([]byte, len(data))
+
+	for i := 0; i < len(data); i++ {
+		result[i] = data[i] ^ key[i%len(key)]
+	}
+
+	return result
+}
+
+func legacyDeriveKey(password string, length int) []byte {
+	key := make([]byte, length)
+
+	for i := 0; i < length; i++ {
+		key[i] = password[i%len(password)] ^ byte(i&0xFF)
+	}
+
+	return key
+}
+
+// legacyHashPassword is the unsalted SHA-256 stored as HASH: in 1.0 files
+func legacyHashPassword(password string) string {
+	hash := sha256.Sum256([]byte(password))
+	return fmt.Sprintf("%x", hash)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorFinishedMsg arrives when the external editor exits. noteID is the
+// note being edited from the browser, or "" when editing the note form.
+type editorFinishedMsg struct {
+	path    string
+	noteID  string
+	content string // what was written to the file, to detect no-op edits
+	err     error
+}
+
+// editorCommand picks $VISUAL, then $EDITOR, then a platform default. The
+// variable may hold arguments, e.g. "code --wait".
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// openInEditor writes content to a private temp file and suspends the TUI
+// while the user's editor runs on it
+func openInEditor(content, noteID string) tea.Cmd {
+	file, err := os.CreateTemp(secureTempDir(), "alpaka-*.md")
+	if err != nil {
+		return editorError(err)
+	}
+	path := file.Name()
+
+	// CreateTemp already uses 0600, make sure a umask can't widen it
+	if err := file.Chmod(0600); err == nil {
+		_, err = file.WriteString(content)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		shredFile(path)
+		return editorError(err)
+	}
+
+	args := append(editorCommand(), path)
+	cmd := exec.Command(args[0], args[1:]...)
+
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorFinishedMsg{path: path, noteID: noteID, content: content, err: err}
+	})
+}
+
+func editorError(err error) tea.Cmd {
+	return func() tea.Msg {
+		return editorFinishedMsg{err: err}
+	}
+}
+
+// readEditedFile returns what the editor left in the file and destroys it.
+// Editors usually add a final newline; it is dropped again if the original
+// text didn't end with one.
+func readEditedFile(msg editorFinishedMsg) (string, error) {
+	defer shredFile(msg.path)
+
+	if msg.err != nil {
+		return "", fmt.Errorf("editor: %w", msg.err)
+	}
+
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		return "", err
+	}
+
+	edited := string(data)
+	if !strings.HasSuffix(msg.content, "\n") {
+		edited = strings.TrimSuffix(strings.TrimSuffix(edited, "\n"), "\r")
+	}
+	return edited, nil
+}
+
+// secureTempDir prefers memory-backed directories so decrypted notes don't
+// reach the disk
+func secureTempDir() string {
+	candidates := []string{os.Getenv("XDG_RUNTIME_DIR")}
+	if runtime.GOOS == "linux" {
+		candidates = append(candidates, "/dev/shm")
+	}
+
+	for _, dir := range candidates {
+		if dir == "" {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return os.TempDir()
+}
+
+// shredFile overwrites a file with zeros before removing it. On journaling
+// or copy-on-write filesystems this is best effort, which is why the file
+// is put on tmpfs when possible.
+func shredFile(path string) {
+	if path == "" {
+		return
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if file, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+			file.Write(make([]byte, info.Size()))
+			file.Sync()
+			file.Close()
+		}
+	}
+	os.Remove(path)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// defaultFileMode is used for notebooks that don't exist yet
+const defaultFileMode fs.FileMode = 0600
+
+// writeFileAtomic replaces filename with data so that a crash at any point
+// leaves either the old or the new file on disk, never a truncated one.
+// The data goes to a temp file in the same directory, is fsynced, renamed
+// over the original, and the directory is fsynced to persist the rename.
+// An existing file keeps its permissions.
+func writeFileAtomic(filename string, data []byte) (err error) {
+	mode := defaultFileMode
+	if info, statErr := os.Stat(filename); statErr == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(statErr, fs.ErrNotExist) {
+		return statErr
+	}
+
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	// Never leave the temp file behind on failure
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if err = tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, filename); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir flushes directory entries (renames, creates) to disk. Windows
+// can't open directories for syncing and persists renames on its own.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing func(path string) // sets up what is at path before the write
+		wantMode fs.FileMode
+		wantErr  bool
+	}{
+		{"new file", nil, defaultFileMode, false},
+		{"replaces a file and keeps its mode", func(path string) {
+			os.WriteFile(path, []byte("old contents that are longer"), 0o640)
+			os.Chmod(path, 0o640)
+		}, 0o640, false},
+		{"fails over a directory", func(path string) {
+			os.MkdirAll(filepath.Join(path, "child"), 0o700)
+		}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "notes.alpaka")
+			if tt.existing != nil {
+				tt.existing(path)
+			}
+
+			err := writeFileAtomic(path, []byte("new"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeFileAtomic error = %v, want error %v", err, tt.wantErr)
+			}
+
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 1 {
+				var names []string
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				t.Errorf("directory holds %v, want only the target", names)
+			}
+			if tt.wantErr {
+				if _, err := os.Stat(filepath.Join(path, "child")); err != nil {
+					t.Errorf("what was at the path was touched: %v", err)
+				}
+				return
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil || string(data) != "new" {
+				t.Errorf("file holds %q, %v; want %q", data, err, "new")
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != tt.wantMode {
+				t.Errorf("mode = %v, want %v", info.Mode().Perm(), tt.wantMode)
+			}
+		})
+	}
+}
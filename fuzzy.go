@@ -0,0 +1,290 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Fuzzy search looks at titles and tags only and forgives typos. Each word
+// of the query has to turn up in the title or a tag either as a
+// subsequence, scored the way fzf does (letters in a row and at the start
+// of words count more, gaps count against), or within a small edit
+// distance of a word, for misspellings like "relaese".
+
+// Subsequence scoring, per matched letter
+const (
+	fuzzyMatchScore     = 16
+	fuzzyWordStartBonus = 8
+	fuzzyConsecutive    = 6
+	fuzzyGapPenalty     = 1
+
+	// tags rank a little below titles
+	fuzzyTagWeight = 0.8
+)
+
+// fuzzyResult is a note found by FuzzySearch, with the letters that
+// matched for highlighting
+type fuzzyResult struct {
+	Note
+	Score        float64
+	TitleMatches []int         // rune positions in the title
+	TagMatches   map[int][]int // rune positions per tag index
+}
+
+// FuzzySearch returns the notes whose title or tags match every word of
+// query, best match first
+func (n *Notebook) FuzzySearch(query string) []fuzzyResult {
+	words := strings.Fields(foldText(query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	var results []fuzzyResult
+	for _, note := range n.Notes {
+		if result, ok := fuzzyMatchNote(note, words); ok {
+			results = append(results, result)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Created.After(results[j].Created)
+	})
+	return results
+}
+
+// fuzzyMatchNote matches each word against the title and the tags and
+// keeps the best place for it
+func fuzzyMatchNote(note Note, words []string) (fuzzyResult, bool) {
+	result := fuzzyResult{Note: note, TagMatches: make(map[int][]int)}
+	title := newFoldedText(note.Title)
+	tags := make([]foldedText, len(note.Tags))
+	for i, tag := range note.Tags {
+		tags[i] = newFoldedText(tag)
+	}
+
+	for _, word := range words {
+		query := []rune(word)
+		best, positions := fuzzyMatch(query, title)
+		tag := -1
+		for i := range tags {
+			score, matched := fuzzyMatch(query, tags[i])
+			if score*fuzzyTagWeight > best {
+				best, positions, tag = score*fuzzyTagWeight, matched, i
+			}
+		}
+		if best <= 0 {
+			return fuzzyResult{}, false
+		}
+
+		result.Score += best
+		if tag < 0 {
+			result.TitleMatches = append(result.TitleMatches, positions...)
+		} else {
+			result.TagMatches[tag] = append(result.TagMatches[tag], positions...)
+		}
+	}
+	return result, true
+}
+
+// foldedText is text folded like the search index does it, remembering
+// which original rune each folded one came from
+type foldedText struct {
+	runes  []rune
+	origin []int
+}
+
+func newFoldedText(text string) foldedText {
+	var folded foldedText
+	for i, r := range []rune(text) {
+		for _, f := range foldText(string(r)) {
+			folded.runes = append(folded.runes, f)
+			folded.origin = append(folded.origin, i)
+		}
+	}
+	return folded
+}
+
+// fuzzyMatch scores query against text, trying a subsequence first and an
+// edit distance after. positions are runes of the original text; a score
+// of 0 means no match.
+func fuzzyMatch(query []rune, text foldedText) (float64, []int) {
+	if score, matched := subsequenceMatch(query, text.runes); score > 0 {
+		return score, text.originOf(matched)
+	}
+	if score, matched := typoMatch(query, text.runes); score > 0 {
+		return score, text.originOf(matched)
+	}
+	return 0, nil
+}
+
+// originOf maps folded positions back to the original runes
+func (t foldedText) originOf(positions []int) []int {
+	var origin []int
+	for _, p := range positions {
+		if len(origin) == 0 || origin[len(origin)-1] != t.origin[p] {
+			origin = append(origin, t.origin[p])
+		}
+	}
+	return origin
+}
+
+// subsequenceMatch finds the best placement of query's letters, in order,
+// in text
+func subsequenceMatch(query, text []rune) (float64, []int) {
+	m, n := len(query), len(text)
+	if m == 0 || m > n {
+		return 0, nil
+	}
+
+	const none = -1 << 30
+	// score[i][j] is the best score with query[i] matched at text[j], and
+	// from[i][j] where query[i-1] was matched for it
+	score := make([][]int, m)
+	from := make([][]int, m)
+	for i := range score {
+		score[i] = make([]int, n)
+		from[i] = make([]int, n)
+	}
+
+	for i := 0; i < m; i++ {
+		// The gap penalty is linear, so the best earlier placement k of
+		// query[i-1] is kept as score + penalty*k and the penalty up to j
+		// taken off when it is used
+		best, bestAt := none, -1
+		for j := 0; j < n; j++ {
+			if i > 0 && j >= 2 && score[i-1][j-2] != none {
+				if candidate := score[i-1][j-2] + fuzzyGapPenalty*(j-2); candidate > best {
+					best, bestAt = candidate, j-2
+				}
+			}
+
+			score[i][j] = none
+			if query[i] != text[j] {
+				continue
+			}
+
+			points := fuzzyMatchScore
+			if j == 0 || !isWordRune(text[j-1]) {
+				points += fuzzyWordStartBonus
+			}
+			if i == 0 {
+				score[i][j] = points
+				continue
+			}
+			if j > 0 && score[i-1][j-1] != none {
+				score[i][j] = score[i-1][j-1] + points + fuzzyConsecutive
+				from[i][j] = j - 1
+			}
+			if best != none {
+				if gapped := best - fuzzyGapPenalty*(j-1) + points; gapped > score[i][j] {
+					score[i][j] = gapped
+					from[i][j] = bestAt
+				}
+			}
+		}
+	}
+
+	end, top := -1, none
+	for j := 0; j < n; j++ {
+		if score[m-1][j] > top {
+			end, top = j, score[m-1][j]
+		}
+	}
+	if end < 0 {
+		return 0, nil
+	}
+
+	positions := make([]int, m)
+	for i, j := m-1, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	return float64(top), positions
+}
+
+// typoMatch compares query with each word of text, and with the start of
+// longer words, allowing a few typos. The matched word is highlighted.
+func typoMatch(query, text []rune) (float64, []int) {
+	allowed := typosAllowed(len(query))
+	if allowed == 0 {
+		return 0, nil
+	}
+
+	bestScore, bestStart, bestEnd := 0.0, 0, 0
+	for start := 0; start < len(text); {
+		if !isWordRune(text[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(text) && isWordRune(text[end]) {
+			end++
+		}
+
+		word := text[start:end]
+		candidates := [][]rune{word}
+		if len(word) > len(query)+allowed {
+			candidates = append(candidates, word[:len(query)])
+		}
+		for _, candidate := range candidates {
+			distance := editDistance(query, candidate)
+			if distance > allowed {
+				continue
+			}
+			// Worth less than a clean subsequence of the same length
+			score := float64(len(query)*fuzzyMatchScore) * (1 - float64(distance)/float64(len(query))) / 2
+			if score > bestScore {
+				bestScore, bestStart, bestEnd = score, start, start+len(candidate)
+			}
+		}
+		start = end
+	}
+
+	var positions []int
+	for i := bestStart; i < bestEnd; i++ {
+		positions = append(positions, i)
+	}
+	return bestScore, positions
+}
+
+// typosAllowed grows with the length of the word; very short words have
+// to be typed right
+func typosAllowed(length int) int {
+	switch {
+	case length <= 3:
+		return 0
+	case length <= 7:
+		return 1
+	}
+	return 2
+}
+
+// editDistance is the Damerau–Levenshtein distance (optimal string
+// alignment): insertions, deletions, substitutions and swapped neighbours
+// each count as one typo
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(min(d[i-1][j]+1, d[i][j-1]+1), d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFuzzySearch(t *testing.T) {
+	notebook := NewNotebook("", "")
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, note := range []Note{
+		{Title: "Release notes"},
+		{Title: "Groceries", Tags: []string{"home"}},
+		{Title: "Zażółć gęślą jaźń"},
+		{Title: "Weekly meeting", Tags: []string{"work"}},
+	} {
+		note.ID = string(rune('a' + i))
+		note.Created = created.Add(time.Duration(i) * time.Hour)
+		notebook.Notes = append(notebook.Notes, note)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// Subsequences
+		{"relnts", []string{"Release notes"}},
+		{"wkly", []string{"Weekly meeting"}},
+		{"wrk", []string{"Weekly meeting"}},
+		{"zazolc", []string{"Zażółć gęślą jaźń"}},
+		// Typos: swapped neighbours, a wrong, missing or extra letter
+		{"relaese", []string{"Release notes"}},
+		{"hmoe", []string{"Groceries"}},
+		{"releese", []string{"Release notes"}},
+		{"relase", []string{"Release notes"}},
+		{"releasse", []string{"Release notes"}},
+		{"relaese notse", []string{"Release notes"}},
+		{"meetnig", []string{"Weekly meeting"}},
+		// Too many typos for the length, or any typo in a short word
+		{"rleaese", nil},
+		{"teh", nil},
+		{"relaese zzz", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, result := range notebook.FuzzySearch(tt.query) {
+			got = append(got, result.Title)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FuzzySearch(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyHighlights(t *testing.T) {
+	tests := []struct {
+		query, title string
+		want         []int
+	}{
+		{"relnts", "Release notes", []int{0, 1, 2, 8, 10, 12}},
+		{"relaese", "Release notes", []int{0, 1, 2, 3, 4, 5, 6}},
+		{"notse", "Release notes", []int{8, 9, 10, 11, 12}},
+		{"zolc", "Zażółć", []int{2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		result, ok := fuzzyMatchNote(Note{Title: tt.title}, []string{tt.query})
+		if !ok {
+			t.Errorf("%q doesn't match %q", tt.query, tt.title)
+			continue
+		}
+		if !reflect.DeepEqual(result.TitleMatches, tt.want) {
+			t.Errorf("%q in %q highlights %v, want %v", tt.query, tt.title, result.TitleMatches, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"release", "release", 0},
+		{"relaese", "release", 1},
+		{"releese", "release", 1},
+		{"relase", "release", 1},
+		{"rleaese", "release", 2},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Syntax highlighting for fenced code blocks. Each language is described by
+// its keywords and how it writes comments and strings, which is enough for
+// a readable preview without a full lexer per language.
+
+type tokenKind int
+
+const (
+	tokenPlain tokenKind = iota
+	tokenKeyword
+	tokenType
+	tokenString
+	tokenNumber
+	tokenComment
+	tokenFunction
+	tokenVariable
+	tokenPunct
+)
+
+type codeToken struct {
+	Kind tokenKind
+	Text string
+}
+
+type syntax struct {
+	keywords        []string
+	types           []string // builtin types, constants and functions
+	lineComments    []string
+	blockComment    [2]string
+	quotes          string // characters that open a single-line string
+	multilineQuotes string // characters that open a string that may span lines
+	tripleQuotes    bool   // Python docstrings
+	caseInsensitive bool   // SQL keywords
+	variables       bool   // shell $VAR and ${VAR}
+	callSuffix      byte   // an identifier followed by this is a function (or a key)
+
+	keywordSet map[string]bool
+	typeSet    map[string]bool
+}
+
+var syntaxes = map[string]*syntax{
+	"go": {
+		keywords: strings.Fields(`break case chan const continue default defer else fallthrough for func
+			go goto if import interface map package range return select struct switch type var`),
+		types: strings.Fields(`bool byte complex64 complex128 error float32 float64 int int8 int16 int32
+			int64 rune string uint uint8 uint16 uint32 uint64 uintptr any true false nil iota append cap
+			close copy delete len make new panic print println recover`),
+		lineComments:    []string{"//"},
+		blockComment:    [2]string{"/*", "*/"},
+		quotes:          `"'`,
+		multilineQuotes: "`",
+		callSuffix:      '(',
+	},
+	"python": {
+		keywords: strings.Fields(`and as assert async await break class continue def del elif else except
+			finally for from global if import in is lambda nonlocal not or pass raise return try while
+			with yield match case`),
+		types: strings.Fields(`True False None int str float bool list dict set tuple bytes object self
+			cls print len range open type isinstance super enumerate zip map filter sorted`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		tripleQuotes: true,
+		callSuffix:   '(',
+	},
+	"js": {
+		keywords: strings.Fields(`break case catch class const continue debugger default delete do else
+			export extends finally for function if import in instanceof let new return super switch this
+			throw try typeof var void while with yield async await of static get set interface type enum
+			implements private public protected readonly as from`),
+		types: strings.Fields(`true false null undefined NaN Infinity console Object Array String Number
+			Boolean Promise Math JSON Date Error Map Set string number boolean any never unknown`),
+		lineComments:    []string{"//"},
+		blockComment:    [2]string{"/*", "*/"},
+		quotes:          `"'`,
+		multilineQuotes: "`",
+		callSuffix:      '(',
+	},
+	"sql": {
+		keywords: strings.Fields(`select from where and or not insert into values update set delete create
+			table drop alter add column primary key foreign references join inner left right outer full
+			cross on as group by order having limit offset distinct union all exists in is null like
+			ilike between case when then else end index view default unique check constraint begin
+			commit rollback transaction returning with asc desc if replace grant revoke`),
+		types: strings.Fields(`int integer bigint smallint varchar char text boolean bool date time
+			timestamp timestamptz numeric decimal float real double serial uuid json jsonb true false
+			count sum avg min max coalesce now`),
+		lineComments:    []string{"--"},
+		blockComment:    [2]string{"/*", "*/"},
+		quotes:          `'"`,
+		caseInsensitive: true,
+		callSuffix:      '(',
+	},
+	"bash": {
+		keywords: strings.Fields(`if then else elif fi for while until do done case esac function in
+			select return exit local export readonly declare set unset shift source alias break continue`),
+		types: strings.Fields(`echo printf cd pwd read test true false eval exec trap sudo cat grep sed
+			awk ls rm mkdir cp mv chmod chown curl git go make`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		variables:    true,
+	},
+	"json": {
+		keywords: []string{"true", "false", "null"},
+		quotes:   `"`,
+	},
+	"yaml": {
+		keywords:     strings.Fields(`true false null yes no on off`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		callSuffix:   ':',
+	},
+	"rust": {
+		keywords: strings.Fields(`as break const continue crate else enum extern fn for if impl in let
+			loop match mod move mut pub ref return self Self static struct super trait type unsafe use
+			where while async await dyn`),
+		types: strings.Fields(`i8 i16 i32 i64 i128 isize u8 u16 u32 u64 u128 usize f32 f64 bool char str
+			String Vec Option Result Some None Ok Err Box true false`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"`,
+		callSuffix:   '(',
+	},
+	"c": {
+		keywords: strings.Fields(`auto break case const continue default do else enum extern for goto if
+			inline register return sizeof static struct switch typedef union volatile while class
+			namespace template typename public private protected virtual override new delete this using
+			include define ifdef ifndef endif pragma`),
+		types: strings.Fields(`char double float int long short signed unsigned void bool size_t int8_t
+			int16_t int32_t int64_t uint8_t uint16_t uint32_t uint64_t std string vector nullptr NULL
+			true false`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		callSuffix:   '(',
+	},
+	"java": {
+		keywords: strings.Fields(`abstract assert break case catch class const continue default do else
+			enum extends final finally for if implements import instanceof interface native new package
+			private protected public return static super switch synchronized this throw throws transient
+			try volatile while var record`),
+		types: strings.Fields(`boolean byte char double float int long short void String Integer Long
+			Boolean Object List Map System true false null`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		callSuffix:   '(',
+	},
+}
+
+// syntaxAliases maps fence info strings to the languages above
+var syntaxAliases = map[string]string{
+	"golang": "go",
+	"py":     "python", "python3": "python",
+	"javascript": "js", "jsx": "js", "ts": "js", "typescript": "js", "tsx": "js", "node": "js",
+	"sh": "bash", "shell": "bash", "zsh": "bash", "console": "bash",
+	"postgres": "sql", "postgresql": "sql", "mysql": "sql", "sqlite": "sql", "psql": "sql",
+	"yml": "yaml",
+	"rs":  "rust",
+	"h":   "c", "cpp": "c", "c++": "c", "cc": "c", "hpp": "c",
+}
+
+func lookupSyntax(lang string) *syntax {
+	lang = strings.ToLower(lang)
+	if alias, ok := syntaxAliases[lang]; ok {
+		lang = alias
+	}
+	s := syntaxes[lang]
+	if s != nil && s.keywordSet == nil {
+		s.keywordSet = wordSet(s.keywords, s.caseInsensitive)
+		s.typeSet = wordSet(s.types, s.caseInsensitive)
+	}
+	return s
+}
+
+func wordSet(words []string, caseInsensitive bool) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		if caseInsensitive {
+			word = strings.ToLower(word)
+		}
+		set[word] = true
+	}
+	return set
+}
+
+// highlightCode splits code into tokens, one slice per line. Unknown
+// languages come back as plain text.
+func highlightCode(lang string, lines []string) [][]codeToken {
+	code := strings.Join(lines, "\n")
+	var tokens []codeToken
+	if s := lookupSyntax(lang); s != nil {
+		tokens = s.tokenize(code)
+	} else {
+		tokens = []codeToken{{tokenPlain, code}}
+	}
+
+	// Split tokens that span lines (block comments, raw strings)
+	result := [][]codeToken{nil}
+	for _, token := range tokens {
+		for i, part := range strings.Split(token.Text, "\n") {
+			if i > 0 {
+				result = append(result, nil)
+			}
+			if part != "" {
+				last := len(result) - 1
+				result[last] = append(result[last], codeToken{token.Kind, part})
+			}
+		}
+	}
+	return result
+}
+
+func (s *syntax) tokenize(code string) []codeToken {
+	var tokens []codeToken
+	emit := func(kind tokenKind, text string) {
+		// Merge runs of the same kind to keep the number of styles down
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, codeToken{kind, text})
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		c := code[i]
+
+		if s.blockComment[0] != "" && strings.HasPrefix(rest, s.blockComment[0]) {
+			end := strings.Index(rest[len(s.blockComment[0]):], s.blockComment[1])
+			n := len(rest)
+			if end >= 0 {
+				n = len(s.blockComment[0]) + end + len(s.blockComment[1])
+			}
+			emit(tokenComment, rest[:n])
+			i += n
+			continue
+		}
+
+		if s.isLineComment(code, i) {
+			n := strings.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+			emit(tokenComment, rest[:n])
+			i += n
+			continue
+		}
+
+		if s.tripleQuotes && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)) {
+			end := strings.Index(rest[3:], rest[:3])
+			n := len(rest)
+			if end >= 0 {
+				n = 3 + end + 3
+			}
+			emit(tokenString, rest[:n])
+			i += n
+			continue
+		}
+
+		if strings.IndexByte(s.quotes, c) >= 0 || strings.IndexByte(s.multilineQuotes, c) >= 0 {
+			n := scanString(rest, strings.IndexByte(s.multilineQuotes, c) >= 0)
+			emit(tokenString, rest[:n])
+			i += n
+			continue
+		}
+
+		if s.variables && c == '$' && len(rest) > 1 {
+			n := 1
+			if rest[1] == '{' {
+				if end := strings.IndexByte(rest, '}'); end > 0 {
+					n = end + 1
+				}
+			} else {
+				for n < len(rest) && (isIdentByte(rest[n]) || n == 1 && strings.IndexByte("?#@*!$0123456789", rest[n]) >= 0) {
+					n++
+				}
+			}
+			emit(tokenVariable, rest[:n])
+			i += n
+			continue
+		}
+
+		if isDigit(c) && (i == 0 || !isIdentByte(code[i-1])) {
+			n := 1
+			for n < len(rest) && (isIdentByte(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			emit(tokenNumber, rest[:n])
+			i += n
+			continue
+		}
+
+		if isIdentByte(c) && !isDigit(c) {
+			n := 1
+			for n < len(rest) && isIdentByte(rest[n]) {
+				n++
+			}
+			emit(s.classify(rest[:n], strings.TrimLeft(rest[n:], " \t")), rest[:n])
+			i += n
+			continue
+		}
+
+		if c < 0x80 && strings.IndexByte(" \t\n", c) < 0 {
+			emit(tokenPunct, rest[:1])
+		} else {
+			emit(tokenPlain, rest[:1])
+		}
+		i++
+	}
+	return tokens
+}
+
+func (s *syntax) isLineComment(code string, i int) bool {
+	for _, prefix := range s.lineComments {
+		if !strings.HasPrefix(code[i:], prefix) {
+			continue
+		}
+		// In shell a # inside a word ($#, foo#bar) doesn't start a comment
+		if prefix == "#" && s.variables && i > 0 && !strings.ContainsRune(" \t\n;", rune(code[i-1])) {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (s *syntax) classify(word, after string) tokenKind {
+	key := word
+	if s.caseInsensitive {
+		key = strings.ToLower(word)
+	}
+	switch {
+	case s.keywordSet[key]:
+		return tokenKeyword
+	case s.typeSet[key]:
+		return tokenType
+	case s.callSuffix != 0 && after != "" && after[0] == s.callSuffix:
+		return tokenFunction
+	}
+	return tokenPlain
+}
+
+// scanString returns the length of the string literal at the start of s,
+// up to the closing quote, the end of the line, or the end of the code.
+func scanString(s string, multiline bool) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote != '`':
+			i++
+		case s[i] == quote:
+			return i + 1
+		case s[i] == '\n' && !multiline:
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// tokenStyles colour code on the code block background
+var tokenStyles = map[tokenKind]lipgloss.Style{
+	tokenPlain:    mdCodeStyle.Copy(),
+	tokenKeyword:  mdCodeStyle.Copy().Foreground(secondary).Bold(true),
+	tokenType:     mdCodeStyle.Copy().Foreground(warning),
+	tokenString:   mdCodeStyle.Copy().Foreground(success),
+	tokenNumber:   mdCodeStyle.Copy().Foreground(lipgloss.Color("#F78C6C")),
+	tokenComment:  mdCodeStyle.Copy().Foreground(muted).Italic(true),
+	tokenFunction: mdCodeStyle.Copy().Foreground(accent),
+	tokenVariable: mdCodeStyle.Copy().Foreground(lipgloss.Color("#89DDFF")),
+	tokenPunct:    mdCodeStyle.Copy().Foreground(lipgloss.Color("#89DDFF")),
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"strings"
+	"time"
+)
+
+// Revision is an earlier version of a note. Revisions are only ever
+// appended; the content is stored DEFLATE-compressed to keep long
+// histories small inside the encrypted payload.
+type Revision struct {
+	Modified time.Time `json:"modified"` // when this version was written
+	Title    string    `json:"title"`
+	Tags     []string  `json:"tags"`
+	Content  []byte    `json:"content"`
+}
+
+func newRevision(note Note) Revision {
+	return Revision{
+		Modified: note.Modified,
+		Title:    note.Title,
+		Tags:     note.Tags,
+		Content:  compressText(note.Content),
+	}
+}
+
+// Text returns the revision's content
+func (r Revision) Text() (string, error) {
+	return decompressText(r.Content)
+}
+
+func compressText(s string) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func decompressText(data []byte) (string, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+// noteVersion is one entry of a note's history as shown on the history
+// screen: a revision or the current version.
+type noteVersion struct {
+	Modified time.Time
+	Title    string
+	Tags     []string
+	Content  string
+	Current  bool
+}
+
+// versions lists every version of a note, oldest first, ending with the
+// current one.
+func (note Note) versions() ([]noteVersion, error) {
+	versions := make([]noteVersion, 0, len(note.Revisions)+1)
+	for _, revision := range note.Revisions {
+		content, err := revision.Text()
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, noteVersion{
+			Modified: revision.Modified,
+			Title:    revision.Title,
+			Tags:     revision.Tags,
+			Content:  content,
+		})
+	}
+
+	return append(versions, noteVersion{
+		Modified: note.Modified,
+		Title:    note.Title,
+		Tags:     note.Tags,
+		Content:  note.Content,
+		Current:  true,
+	}), nil
+}
+
+func equalTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// === DIFF ===
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffInsert
+	diffDelete
+)
+
+type diffLine struct {
+	Op   diffOp
+	Text string
+}
+
+// diffLines compares two texts line by line using the longest common
+// subsequence. Notes are small enough for the quadratic table.
+func diffLines(before, after string) []diffLine {
+	a := splitLines(before)
+	b := splitLines(after)
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, diffLine{diffEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, diffLine{diffDelete, a[i]})
+			i++
+		default:
+			diff = append(diff, diffLine{diffInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, diffLine{diffDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, diffLine{diffInsert, b[j]})
+	}
+
+	return diff
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
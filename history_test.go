@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompressText(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"hello",
+		"Zażółć gęślą jaźń 🦙",
+		strings.Repeat("a long line that repeats\n", 500),
+	} {
+		data := compressText(text)
+		got, err := decompressText(data)
+		if err != nil || got != text {
+			t.Errorf("round trip of %d bytes = %d bytes, %v", len(text), len(got), err)
+		}
+		if len(text) > 1000 && len(data) > len(text)/10 {
+			t.Errorf("%d bytes compressed to %d", len(text), len(data))
+		}
+	}
+
+	if _, err := decompressText([]byte("not deflate")); err == nil {
+		t.Error("decompressing garbage succeeded")
+	}
+}
+
+// showDiff prints a diff as " a", "-b", "+c" lines joined by "|"
+func showDiff(diff []diffLine) string {
+	marks := map[diffOp]string{diffEqual: " ", diffInsert: "+", diffDelete: "-"}
+	var lines []string
+	for _, line := range diff {
+		lines = append(lines, marks[line.Op]+line.Text)
+	}
+	return strings.Join(lines, "|")
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		before, after string
+		want          string
+	}{
+		{"", "", ""},
+		{"a\nb", "a\nb", " a| b"},
+		{"", "a\nb", "+a|+b"},
+		{"a\nb", "", "-a|-b"},
+		{"a\nb\nc", "a\nc", " a|-b| c"},
+		{"a\nc", "a\nb\nc", " a|+b| c"},
+		{"a\nb\nc", "a\nB\nc", " a|-b|+B| c"},
+		{"x\na\nb", "a\nb\ny", "-x| a| b|+y"},
+		{"a\nb\nc\nd", "b\nd\na", "-a| b|-c| d|+a"},
+	}
+	for _, tt := range tests {
+		if got := showDiff(diffLines(tt.before, tt.after)); got != tt.want {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	notebook := NewNotebook("", "")
+	note := NewNote("v1", "first", []string{"a"})
+	notebook.AddNote(note)
+	notebook.UpdateNote(note.ID, "v2", "second", []string{"a"})
+	notebook.UpdateNote(note.ID, "v2", "second", []string{"a"}) // unchanged, no revision
+	notebook.UpdateNote(note.ID, "v3", "third", nil)
+
+	if err := notebook.RestoreRevision(note.ID, 0); err != nil {
+		t.Fatalf("RestoreRevision: %v", err)
+	}
+	if err := notebook.RestoreRevision(note.ID, 9); err == nil {
+		t.Error("restoring a missing revision succeeded")
+	}
+
+	current, _ := notebook.GetNote(note.ID)
+	versions, err := current.versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, version := range versions {
+		got = append(got, version.Title+":"+version.Content)
+	}
+	want := "v1:first v2:second v3:third v1:first"
+	if strings.Join(got, " ") != want {
+		t.Errorf("versions = %v, want %s", got, want)
+	}
+	if !versions[len(versions)-1].Current || !current.Created.Equal(note.Created) {
+		t.Error("the last version isn't the current one or the creation time changed")
+	}
+}
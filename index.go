@@ -0,0 +1,366 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// The search index maps every word in the notebook to the notes that
+// contain it. It is built when the notebook is loaded and kept up to date
+// as notes are added, edited and deleted, so a search only looks at the
+// notes that can match. Words are folded to lower case without
+// diacritics, so "zażółć" and "zazolc" are the same word.
+
+// Note fields, in the order they are weighted for ranking
+const (
+	fieldTitle = iota
+	fieldTags
+	fieldContent
+	numFields
+
+	anyField = -1
+)
+
+// fieldWeights makes a hit in the title count more than one in the tags,
+// and that more than one in the body
+var fieldWeights = [numFields]float64{3, 2, 1}
+
+// BM25 parameters: how quickly repeated words stop adding to the score,
+// and how much long notes are penalised
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// prefixWeight scales hits on a longer word, such as "release" for
+// "rel", below exact hits
+const prefixWeight = 0.5
+
+type noteSet map[string]bool
+
+// indexedNote is what the index keeps about a note
+type indexedNote struct {
+	tokens  [numFields][]string // folded words in order
+	tags    []string            // folded whole tags
+	words   int                 // as counted in the detailed view
+	created time.Time
+}
+
+type searchIndex struct {
+	postings map[string]map[string]*[numFields]int // word -> note ID -> hits per field
+	notes    map[string]*indexedNote
+	length   [numFields]int // total words per field, for the average
+	filled   [numFields]int // notes with any words in each field
+
+	vocabulary []string // sorted words, for prefix lookups
+	sorted     bool
+}
+
+func newSearchIndex(notes []Note) *searchIndex {
+	ix := &searchIndex{
+		postings: make(map[string]map[string]*[numFields]int),
+		notes:    make(map[string]*indexedNote),
+	}
+	for i := range notes {
+		ix.add(&notes[i])
+	}
+	return ix
+}
+
+// add indexes a note, replacing what was indexed for it before
+func (ix *searchIndex) add(note *Note) {
+	ix.remove(note.ID)
+
+	doc := &indexedNote{
+		words:   len(strings.Fields(note.Content)),
+		created: note.Created,
+	}
+	doc.tokens[fieldTitle] = tokenize(note.Title)
+	doc.tokens[fieldContent] = tokenize(note.Content)
+	for _, tag := range note.Tags {
+		doc.tags = append(doc.tags, foldText(tag))
+		doc.tokens[fieldTags] = append(doc.tokens[fieldTags], tokenize(tag)...)
+	}
+
+	for field, tokens := range doc.tokens {
+		ix.length[field] += len(tokens)
+		if len(tokens) > 0 {
+			ix.filled[field]++
+		}
+		for _, token := range tokens {
+			notes, ok := ix.postings[token]
+			if !ok {
+				notes = make(map[string]*[numFields]int)
+				ix.postings[token] = notes
+				ix.sorted = false
+			}
+			hits, ok := notes[note.ID]
+			if !ok {
+				hits = new([numFields]int)
+				notes[note.ID] = hits
+			}
+			hits[field]++
+		}
+	}
+	ix.notes[note.ID] = doc
+}
+
+// remove drops a note from the index
+func (ix *searchIndex) remove(id string) {
+	doc, ok := ix.notes[id]
+	if !ok {
+		return
+	}
+	for field, tokens := range doc.tokens {
+		ix.length[field] -= len(tokens)
+		if len(tokens) > 0 {
+			ix.filled[field]--
+		}
+		for _, token := range tokens {
+			notes := ix.postings[token]
+			delete(notes, id)
+			if len(notes) == 0 {
+				delete(ix.postings, token)
+				ix.sorted = false
+			}
+		}
+	}
+	delete(ix.notes, id)
+}
+
+func (ix *searchIndex) all() noteSet {
+	set := make(noteSet, len(ix.notes))
+	for id := range ix.notes {
+		set[id] = true
+	}
+	return set
+}
+
+// expand lists the indexed words equal to token or, with prefix, starting
+// with it
+func (ix *searchIndex) expand(token string, prefix bool) []string {
+	if !prefix {
+		if _, ok := ix.postings[token]; ok {
+			return []string{token}
+		}
+		return nil
+	}
+
+	if !ix.sorted {
+		ix.vocabulary = ix.vocabulary[:0]
+		for word := range ix.postings {
+			ix.vocabulary = append(ix.vocabulary, word)
+		}
+		sort.Strings(ix.vocabulary)
+		ix.sorted = true
+	}
+	var words []string
+	for i := sort.SearchStrings(ix.vocabulary, token); i < len(ix.vocabulary); i++ {
+		if !strings.HasPrefix(ix.vocabulary[i], token) {
+			break
+		}
+		words = append(words, ix.vocabulary[i])
+	}
+	return words
+}
+
+// lookup returns the notes with token in field, or in any field
+func (ix *searchIndex) lookup(token string, prefix bool, field int) noteSet {
+	set := make(noteSet)
+	for _, word := range ix.expand(token, prefix) {
+		for id, hits := range ix.postings[word] {
+			if field == anyField || hits[field] > 0 {
+				set[id] = true
+			}
+		}
+	}
+	return set
+}
+
+// matchWords returns the notes containing tokens as consecutive words. The
+// last token may be the beginning of a word, so results appear while it is
+// still being typed.
+func (ix *searchIndex) matchWords(tokens []string, field int) noteSet {
+	if len(tokens) == 0 {
+		// Nothing searchable, such as "#" on its own
+		return ix.all()
+	}
+
+	last := len(tokens) - 1
+	set := ix.lookup(tokens[last], true, field)
+	for _, token := range tokens[:last] {
+		set = intersect(set, ix.lookup(token, false, field))
+	}
+	if len(tokens) == 1 {
+		return set
+	}
+
+	for id := range set {
+		if !ix.notes[id].hasSequence(tokens, field) {
+			delete(set, id)
+		}
+	}
+	return set
+}
+
+// hasSequence reports whether tokens appear one after another in field
+func (doc *indexedNote) hasSequence(tokens []string, field int) bool {
+	for f := range doc.tokens {
+		if field != anyField && f != field {
+			continue
+		}
+		words := doc.tokens[f]
+	next:
+		for start := 0; start+len(tokens) <= len(words); start++ {
+			for i, token := range tokens {
+				word := words[start+i]
+				if i == len(tokens)-1 && strings.HasPrefix(word, token) || word == token {
+					continue
+				}
+				continue next
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the notes keep accepts
+func (ix *searchIndex) filter(keep func(*indexedNote) bool) noteSet {
+	set := make(noteSet)
+	for id, doc := range ix.notes {
+		if keep(doc) {
+			set[id] = true
+		}
+	}
+	return set
+}
+
+// scoreTerm is a word a search looks for, used to rank the results and
+// to highlight them. next marks the words of a phrase after its first,
+// which only count as a match together with the words before them.
+type scoreTerm struct {
+	token  string
+	prefix bool
+	field  int
+	next   bool
+}
+
+// score ranks a note with BM25, weighting each field by fieldWeights
+func (ix *searchIndex) score(id string, terms []scoreTerm) float64 {
+	doc, ok := ix.notes[id]
+	if !ok {
+		return 0
+	}
+
+	score := 0.0
+	for _, term := range terms {
+		for _, word := range ix.expand(term.token, term.prefix) {
+			notes := ix.postings[word]
+			hits, ok := notes[id]
+			if !ok {
+				continue
+			}
+
+			frequency := 0.0
+			for field := 0; field < numFields; field++ {
+				if hits[field] == 0 || term.field != anyField && field != term.field {
+					continue
+				}
+				// Average over the notes that use the field, so that a
+				// single tag isn't judged against the many untagged notes
+				average := float64(ix.length[field]) / float64(ix.filled[field])
+				norm := 1 - bm25B + bm25B*float64(len(doc.tokens[field]))/average
+				frequency += fieldWeights[field] * float64(hits[field]) / norm
+			}
+
+			idf := ix.idf(word)
+			weight := 1.0
+			if word != term.token {
+				weight = prefixWeight
+				// A longer word is no rarer than the word typed, or
+				// "releases" would outrank "release"
+				if _, ok := ix.postings[term.token]; ok {
+					idf = math.Min(idf, ix.idf(term.token))
+				}
+			}
+			score += weight * idf * frequency / (bm25K1 + frequency)
+		}
+	}
+	return score
+}
+
+// idf is the inverse document frequency of an indexed word
+func (ix *searchIndex) idf(word string) float64 {
+	total := float64(len(ix.notes))
+	found := float64(len(ix.postings[word]))
+	return math.Log(1 + (total-found+0.5)/(found+0.5))
+}
+
+func intersect(a, b noteSet) noteSet {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	set := make(noteSet)
+	for id := range a {
+		if b[id] {
+			set[id] = true
+		}
+	}
+	return set
+}
+
+func union(a, b noteSet) noteSet {
+	set := make(noteSet, len(a)+len(b))
+	for id := range a {
+		set[id] = true
+	}
+	for id := range b {
+		set[id] = true
+	}
+	return set
+}
+
+// tokenize splits text into folded words made of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(foldText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// foldText lower-cases text and strips diacritics: accents are split off
+// and dropped, and letters that don't decompose, like ł, are mapped by
+// hand
+func foldText(text string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if folded, ok := foldedLetters[r]; ok {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var foldedLetters = map[rune]string{
+	'ł': "l",
+	'đ': "d",
+	'ð': "d",
+	'ø': "o",
+	'ħ': "h",
+	'ı': "i",
+	'ŀ': "l",
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+	'þ': "th",
+}
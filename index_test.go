@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// searchIDs runs a query against the index alone and returns the IDs of
+// the matching notes, best match first
+func searchIDs(t *testing.T, ix *searchIndex, query string) []string {
+	t.Helper()
+	parsed, err := parseQuery(query)
+	if err != nil {
+		t.Fatalf("parseQuery(%q): %v", query, err)
+	}
+	terms := parsed.terms()
+	var ids []string
+	for id := range parsed.eval(ix) {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		si, sj := ix.score(ids[i], terms), ix.score(ids[j], terms)
+		if si != sj {
+			return si > sj
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+func TestSearchIndex(t *testing.T) {
+	ix := newSearchIndex([]Note{
+		{ID: "title", Title: "Release plan", Content: "dates and owners"},
+		{ID: "tag", Title: "Trip", Content: "pack the bags", Tags: []string{"release"}},
+		{ID: "content", Title: "Dogs", Content: "release the hounds"},
+		{ID: "prefix", Title: "Releases", Content: "one per month"},
+	})
+
+	steps := []struct {
+		name  string
+		edit  func()
+		query string
+		want  []string
+	}{
+		{"title beats tag beats content, exact beats prefix", nil,
+			"release", []string{"title", "tag", "content", "prefix"}},
+		{"field filter", nil,
+			"title:release", []string{"title", "prefix"}},
+		{"phrase in order", nil,
+			`"the hounds"`, []string{"content"}},
+		{"phrase out of order", nil,
+			`"hounds the"`, nil},
+		{"add", func() {
+			ix.add(&Note{ID: "new", Title: "Zażółć gęślą jaźń", Content: "release"})
+		}, "zazolc", []string{"new"}},
+		{"shorter content ranks higher", nil,
+			"release", []string{"title", "tag", "new", "content", "prefix"}},
+		{"update drops the old words", func() {
+			ix.add(&Note{ID: "title", Title: "Budget", Content: "dates and owners"})
+		}, "release", []string{"tag", "new", "content", "prefix"}},
+		{"update adds the new words", nil,
+			"budget", []string{"title"}},
+		{"remove", func() {
+			ix.remove("content")
+		}, "release", []string{"tag", "new", "prefix"}},
+		{"removed words are gone", nil,
+			"hounds", nil},
+		{"remove twice", func() {
+			ix.remove("content")
+		}, "dates OR pack", []string{"tag", "title"}},
+	}
+
+	for _, step := range steps {
+		if step.edit != nil {
+			step.edit()
+		}
+		if got := searchIDs(t, ix, step.query); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: %q found %v, want %v", step.name, step.query, got, step.want)
+		}
+	}
+
+	// Nothing is left behind for words no note uses any more
+	for _, word := range []string{"plan", "hounds", "dogs"} {
+		if _, ok := ix.postings[word]; ok {
+			t.Errorf("postings still has %q", word)
+		}
+	}
+	rebuilt := newSearchIndex([]Note{
+		{ID: "title", Title: "Budget", Content: "dates and owners"},
+		{ID: "tag", Title: "Trip", Content: "pack the bags", Tags: []string{"release"}},
+		{ID: "prefix", Title: "Releases", Content: "one per month"},
+		{ID: "new", Title: "Zażółć gęślą jaźń", Content: "release"},
+	})
+	if ix.length != rebuilt.length {
+		t.Errorf("field lengths = %v, want %v", ix.length, rebuilt.length)
+	}
+}
+
+func TestFoldText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"zażółć", "zazolc"},
+		{"ZAŻÓŁĆ gęślą jaźń", "zazolc gesla jazn"},
+		{"Łódź", "lodz"},
+		{"Straße", "strasse"},
+		{"Crème brûlée", "creme brulee"},
+		{"Ærø", "aero"},
+		{"plain 123", "plain 123"},
+	}
+	for _, tt := range tests {
+		if got := foldText(tt.in); got != tt.want {
+			t.Errorf("foldText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Screens are sized from the terminal rather than a fixed 80 columns.
+// layoutWidth follows the terminal width within sensible bounds and every
+// box, card and separator is derived from it. Until the first
+// WindowSizeMsg the classic 80-column layout is used.
+
+const (
+	defaultLayoutWidth = 80
+	minLayoutWidth     = 40
+	maxLayoutWidth     = 120
+
+	// The ASCII logo needs this much room; smaller terminals get a
+	// one-line header
+	logoWidth     = 58
+	logoMinHeight = 32
+
+	// Narrowest grid card, border excluded
+	minGridCardWidth = 32
+)
+
+// layoutWidth is the width screens are drawn at
+func (m model) layoutWidth() int {
+	if m.width == 0 {
+		return defaultLayoutWidth
+	}
+	return max(minLayoutWidth, min(m.width-2, maxLayoutWidth))
+}
+
+// boxWidth is the Width of input and info boxes, 70 on an 80-column
+// terminal
+func (m model) boxWidth() int {
+	return m.layoutWidth() - 10
+}
+
+// cardWidth is the Width of full-width note cards. It leaves room for the
+// border and the viewport's scrollbar.
+func (m model) cardWidth() int {
+	return m.layoutWidth() - 5
+}
+
+// cardTextWidth is the room inside a card of the given Width once its
+// padding is taken off
+func cardTextWidth(width int) int {
+	return width - noteCardStyle.GetHorizontalPadding()
+}
+
+// gridLayout picks how many cards fit side by side and how wide each one
+// is, border excluded
+func (m model) gridLayout() (columns, width int) {
+	room := m.layoutWidth() - 2 // scrollbar
+	border := noteCardStyle.GetHorizontalBorderSize()
+
+	columns = max(1, room/(minGridCardWidth+border))
+	return columns, room/columns - border
+}
+
+// compactHeader reports whether the ASCII logo doesn't fit the terminal
+func (m model) compactHeader() bool {
+	if m.width == 0 {
+		return false
+	}
+	return m.width < logoWidth || m.height < logoMinHeight
+}
+
+// renderFooter draws the shared footer at the layout width
+func (m model) renderFooter(help string) string {
+	return renderFooter(help, m.layoutWidth())
+}
+
+// wrapHelp breaks a renderHelp line between entries so it fits width
+func wrapHelp(help string, width int) string {
+	var lines []string
+	line := ""
+	for _, part := range strings.Split(help, helpSeparator) {
+		switch {
+		case line == "":
+			line = part
+		case lipgloss.Width(line+helpSeparator+part) <= width:
+			line += helpSeparator + part
+		default:
+			lines = append(lines, line)
+			line = part
+		}
+	}
+	return strings.Join(append(lines, line), "\n")
+}
+
+// flowBlocks lays blocks out left to right, starting a new row whenever
+// the next one wouldn't fit in width
+func flowBlocks(width int, blocks ...string) string {
+	var rows, row []string
+	used := 0
+	for _, block := range blocks {
+		w := lipgloss.Width(block)
+		if len(row) > 0 && used+w > width {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+			row, used = nil, 0
+		}
+		row = append(row, block)
+		used += w
+	}
+	if len(row) > 0 {
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+	}
+	return lipgloss.JoinVertical(lipgloss.Center, rows...)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"strings"
@@ -21,6 +22,11 @@ const (
 	screenSearch
 	screenStats
 	screenSettings
+	screenRestore
+	screenChangePassword
+	screenHistory
+	screenTrash
+	screenNotebooks
 )
 
 type sortMode int
@@ -32,41 +38,87 @@ const (
 )
 
 type model struct {
-	screen        screen
-	notebook      *Notebook
-	filename      string
-	password      string
-	passwordBuf   string
-	titleBuf      string
-	contentBuf    string
-	tagsBuf       string
-	cursor        int
-	selected      int
-	searchQuery   string
-	err           error
-	success       string
-	width         int
-	height        int
-	splashTicks   int
-	ready         bool
-	animFrame     int
-	showPassword  bool
-	sortMode      sortMode
-	viewMode      int // 0 = list, 1 = grid, 2 = detailed
-	filterTag     string
-	scrollOffset  int
-	maxScroll     int
+	screen       screen
+	notebook     *Notebook
+	filename     string
+	password     string
+	passwordBuf  string
+	titleBuf     string
+	content      textArea
+	tagsBuf      string
+	cursor       int
+	selected     int
+	searchQuery  string
+	fuzzySearch  bool
+	err          error
+	success      string
+	width        int
+	height       int
+	splashTicks  int
+	ready        bool
+	animFrame    int
+	showPassword bool
+	sortMode     sortMode
+	viewMode     int // 0 = list, 1 = grid, 2 = detailed
+	filterTag    string
+	scrollOffset int
+	maxScroll    int
+
+	confirmingPassword bool
+	confirmBuf         string
+	loginAttempts      int
+	loginLockedUntil   time.Time
+	pickingNotebook    bool // the notebook list is open on the login screen
+
+	backups        []backupGeneration
+	backupsLoading bool
+
+	oldPasswordBuf string
+	newPasswordBuf string
+
+	confirmQuit bool
+
+	confirmEmptyTrash bool // waiting for y/n before emptying the trash
+
+	editID       string // note being edited in screenAddNote, "" when adding
+	returnScreen screen // where to go after saving an edit
+
+	historyID      string
+	historyVersion int // index into the note's versions, oldest first
+
+	undoID string // last deleted note, restorable with "u"
+
+	codeBlock int // code block selected in the detailed view, from 1; 0 = none
+
+	highlightTerms []scoreTerm // search words highlighted in the detailed view
+	matchIndex     int         // match last jumped to with n/N
+	backToSearch   bool        // the detailed view was opened from the search results
+
+	searchFocus  bool   // the result cards have the keys rather than the query box
+	searchID     string // result under the cursor
+	searchCursor int    // its position, for when the note drops out of the results
+
+	searchCache *searchCache // shared by the copies of the model, see searchHits
+
+	notebookEntries []notebookEntry // choices of the notebook picker and switcher
+	notebookPrompt  notebookPrompt
+	promptBuf       string
+	target          *Notebook // second unlocked notebook notes are moved or copied to
 }
 
+const defaultNotebookFile = "notatki.alpaka"
+
 type tickMsg struct{}
 type animMsg struct{}
 
-func initialModel() model {
+func initialModel(filename string) model {
 	return model{
 		screen:   screenSplash,
-		filename: "notatki.alpaka",
+		filename: filename,
 		sortMode: sortByDate,
 		viewMode: 0,
+
+		searchCache: &searchCache{},
 	}
 }
 
@@ -92,6 +144,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		m.height = msg.Height
 		m.ready = true
+		m.content.SetWidth(m.contentAreaWidth())
+		switch m.screen {
+		case screenViewNotes:
+			m = m.scrollNotes()
+		case screenSearch:
+			m = m.scrollSearch()
+		}
 		return m, nil
 
 	case tickMsg:
@@ -108,40 +167,120 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.animFrame = (m.animFrame + 1) % 4
 		return m, animate()
 
+	case autosaveMsg:
+		return m.handleAutosave(msg)
+
+	case editorFinishedMsg:
+		revision := m.revision()
+		updated, cmd := m.handleEditorFinished(msg)
+		return updated.(model).scheduleAutosave(revision, cmd)
+
+	case clipboardMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("copy failed: %w", msg.err)
+		} else {
+			m.err = nil
+			m.success = msg.what + " copied to the clipboard"
+		}
+		return m, nil
+
+	case backupsLoadedMsg:
+		m.backupsLoading = false
+		m.backups = msg.backups
+		m.err = msg.err
+		return m, nil
+
 	case tea.KeyMsg:
+		if m.confirmQuit {
+			return m.updateQuitDialog(msg)
+		}
+
 		switch msg.String() {
 		case "ctrl+c":
-			return m, tea.Quit
+			return m.quit()
 		case "esc":
+			if m.screen == screenLogin && m.pickingNotebook {
+				m.pickingNotebook = false
+				return m, nil
+			}
+			if m.screen == screenLogin && m.confirmingPassword {
+				m.confirmingPassword = false
+				m.confirmBuf = ""
+				m.passwordBuf = ""
+				m.err = nil
+				return m, nil
+			}
+			if m.screen == screenTrash && m.confirmEmptyTrash {
+				m.confirmEmptyTrash = false
+				return m, nil
+			}
+			if m.screen == screenSearch && m.searchFocus {
+				m.searchFocus = false
+				return m, nil
+			}
+			if m.screen == screenViewNotes && m.backToSearch {
+				m.screen = screenSearch
+				m.backToSearch = false
+				m.highlightTerms = nil
+				m.err = nil
+				m.scrollOffset = 0
+				return m.scrollSearch(), nil
+			}
+			if m.screen == screenNotebooks && m.notebookPrompt != promptNone {
+				m.notebookPrompt = promptNone
+				m.promptBuf = ""
+				m.err = nil
+				return m, nil
+			}
 			if m.screen != screenLogin && m.screen != screenSplash {
 				m.screen = screenMenu
 				m.err = nil
 				m.success = ""
 				m.cursor = 0
 				m.scrollOffset = 0
+				m.highlightTerms = nil
 			}
 			return m, nil
 		}
 
-		switch m.screen {
-		case screenSplash:
-			m.screen = screenLogin
-			return m, nil
-		case screenLogin:
-			return m.updateLogin(msg)
-		case screenMenu:
-			return m.updateMenu(msg)
-		case screenAddNote:
-			return m.updateAddNote(msg)
-		case screenViewNotes:
-			return m.updateViewNotes(msg)
-		case screenSearch:
-			return m.updateSearch(msg)
-		case screenStats:
-			return m.updateStats(msg)
-		case screenSettings:
-			return m.updateSettings(msg)
-		}
+		revision := m.revision()
+		updated, cmd := m.updateScreen(msg)
+		return updated.(model).scheduleAutosave(revision, cmd)
+	}
+
+	return m, nil
+}
+
+// updateScreen hands a key press to the active screen
+func (m model) updateScreen(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch m.screen {
+	case screenSplash:
+		m.screen = screenLogin
+		return m, nil
+	case screenLogin:
+		return m.updateLogin(msg)
+	case screenMenu:
+		return m.updateMenu(msg)
+	case screenAddNote:
+		return m.updateAddNote(msg)
+	case screenViewNotes:
+		return m.updateViewNotes(msg)
+	case screenSearch:
+		return m.updateSearch(msg)
+	case screenStats:
+		return m.updateStats(msg)
+	case screenSettings:
+		return m.updateSettings(msg)
+	case screenRestore:
+		return m.updateRestore(msg)
+	case screenChangePassword:
+		return m.updateChangePassword(msg)
+	case screenHistory:
+		return m.updateHistory(msg)
+	case screenTrash:
+		return m.updateTrash(msg)
+	case screenNotebooks:
+		return m.updateNotebooks(msg)
 	}
 
 	return m, nil
@@ -152,6 +291,10 @@ func (m model) View() string {
 		return "Inicjalizacja..."
 	}
 
+	if m.confirmQuit {
+		return m.viewQuitDialog()
+	}
+
 	switch m.screen {
 	case screenSplash:
 		return m.viewSplash()
@@ -169,13 +312,47 @@ func (m model) View() string {
 		return m.viewStats()
 	case screenSettings:
 		return m.viewSettings()
+	case screenRestore:
+		return m.viewRestore()
+	case screenChangePassword:
+		return m.viewChangePassword()
+	case screenHistory:
+		return m.viewHistory()
+	case screenTrash:
+		return m.viewTrash()
+	case screenNotebooks:
+		return m.viewNotebooks()
 	}
 
 	return ""
 }
 
 func main() {
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if err := runCommand(args[0], args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Błąd: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	flags := flag.NewFlagSet("alpaka", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, cliUsage) }
+	file := flags.String("file", defaultNotebookPath(), "notebook file")
+	flags.Parse(args)
+
+	// alpaka --file work.alpaka list
+	if flags.NArg() > 0 {
+		commandArgs := append([]string{"--file", *file}, flags.Args()[1:]...)
+		if err := runCommand(flags.Arg(0), commandArgs); err != nil {
+			fmt.Fprintf(os.Stderr, "Błąd: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	p := tea.NewProgram(initialModel(*file), tea.WithAltScreen(), tea.WithMouseCellMotion())
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Błąd: %v\n", err)
 		os.Exit(1)
@@ -262,21 +439,6 @@ var (
 				MarginLeft(2).
 				Bold(true)
 
-	// Input styles with focus states
-	inputStyle = lipgloss.NewStyle().
-			Foreground(text).
-			Background(bgDark).
-			Padding(0, 1).
-			Width(70)
-
-	focusedInputStyle = lipgloss.NewStyle().
-				Foreground(text).
-				Background(bgLight).
-				Padding(0, 1).
-				Width(70).
-				Border(lipgloss.NormalBorder()).
-				BorderForeground(accent)
-
 	labelStyle = lipgloss.NewStyle().
 			Foreground(textDim).
 			Bold(true).
@@ -293,7 +455,6 @@ var (
 			BorderForeground(secondary).
 			Padding(1, 2).
 			MarginBottom(1).
-			Width(75).
 			Background(bgDark)
 
 	selectedNoteStyle = lipgloss.NewStyle().
@@ -301,7 +462,6 @@ var (
 				BorderForeground(primary).
 				Padding(1, 2).
 				MarginBottom(1).
-				Width(75).
 				Background(bgLight)
 
 	highlightNoteStyle = lipgloss.NewStyle().
@@ -309,7 +469,6 @@ var (
 				BorderForeground(accent).
 				Padding(1, 2).
 				MarginBottom(1).
-				Width(75).
 				Background(bgLight)
 
 	noteTitleStyle = lipgloss.NewStyle().
@@ -317,6 +476,15 @@ var (
 			Bold(true).
 			Underline(true)
 
+	// Letters a fuzzy search matched in a title
+	matchTitleStyle = noteTitleStyle.Copy().
+			Foreground(warning)
+
+	// Words a search matched in a snippet or the detailed view
+	matchStyle = lipgloss.NewStyle().
+			Foreground(bg).
+			Background(warning)
+
 	noteMetaStyle = lipgloss.NewStyle().
 			Foreground(muted).
 			Italic(true)
@@ -405,7 +573,7 @@ func renderGradientText(text string, colors []lipgloss.Color) string {
 	return result.String()
 }
 
-func renderHeader(title string, subtitle string) string {
+func renderHeader(title string, subtitle string, width int, compact bool) string {
 	logo := `
    ▄▄▄       ██▓     ██▓███   ▄▄▄       ██ ▄█▀▄▄▄      
   ▒████▄    ▓██▒    ▓██░  ██▒▒████▄     ██▄█▒▒████▄    
@@ -417,46 +585,56 @@ func renderHeader(title string, subtitle string) string {
     ░   ▒     ░ ░   ░░         ░   ▒   ░ ░░ ░  ░   ▒   
         ░  ░    ░  ░               ░  ░░  ░        ░  ░`
 
-	gradientLogo := renderGradientText(logo, colorPalette)
-
 	var b strings.Builder
-	b.WriteString(gradientLogo)
-	b.WriteString("\n\n")
-	b.WriteString(lipgloss.NewStyle().
-		Foreground(primary).
-		Bold(true).
-		Align(lipgloss.Center).
-		Width(80).
-		Render("🦙 " + title + " 🦙"))
-	b.WriteString("\n")
+	if compact {
+		// One line instead of the logo on small terminals
+		b.WriteString(lipgloss.NewStyle().
+			Align(lipgloss.Center).
+			Width(width).
+			Render(renderGradientText("ALPAKA NOTES", colorPalette) + "  " +
+				lipgloss.NewStyle().Foreground(primary).Bold(true).Render("🦙 "+title)))
+		b.WriteString("\n")
+	} else {
+		b.WriteString(renderGradientText(logo, colorPalette))
+		b.WriteString("\n\n")
+		b.WriteString(lipgloss.NewStyle().
+			Foreground(primary).
+			Bold(true).
+			Align(lipgloss.Center).
+			Width(width).
+			Render("🦙 " + title + " 🦙"))
+		b.WriteString("\n")
+	}
 
 	if subtitle != "" {
 		b.WriteString(lipgloss.NewStyle().
 			Foreground(muted).
 			Italic(true).
 			Align(lipgloss.Center).
-			Width(80).
+			Width(width).
 			Render(subtitle))
 		b.WriteString("\n")
 	}
 
-	separator := strings.Repeat("━", 80)
+	separator := strings.Repeat("━", width)
 	b.WriteString(lipgloss.NewStyle().Foreground(secondary).Render(separator))
 	b.WriteString("\n")
 
 	return b.String()
 }
 
-func renderFooter(help string) string {
+func renderFooter(help string, width int) string {
 	var b strings.Builder
-	separator := strings.Repeat("━", 80)
+	separator := strings.Repeat("━", width)
 	b.WriteString("\n")
 	b.WriteString(lipgloss.NewStyle().Foreground(secondary).Render(separator))
 	b.WriteString("\n")
-	b.WriteString(helpStyle.Render(help))
+	b.WriteString(helpStyle.Render(wrapHelp(help, width)))
 	return b.String()
 }
 
+const helpSeparator = " │ "
+
 func renderHelp(keys ...string) string {
 	var parts []string
 	for i := 0; i < len(keys); i += 2 {
@@ -466,7 +644,7 @@ func renderHelp(keys ...string) string {
 			parts = append(parts, key+" "+desc)
 		}
 	}
-	return strings.Join(parts, " │ ")
+	return strings.Join(parts, helpSeparator)
 }
 
 func renderProgressBar(current, total int, width int) string {
@@ -489,4 +667,4 @@ func renderProgressBar(current, total int, width int) string {
 func getAnimatedCursor(frame int) string {
 	cursors := []string{"▌", "▐", "▌", "▐"}
 	return cursors[frame%len(cursors)]
-}
\ No newline at end of file
+}
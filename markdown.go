@@ -0,0 +1,767 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+// A small Markdown renderer for the detailed note view. It understands the
+// CommonMark constructs notes actually use (headings, emphasis, lists,
+// blockquotes, links, tables and fenced code) and styles them with the
+// app's palette. Anything it doesn't recognise is shown as plain text.
+
+type mdBlockKind int
+
+const (
+	mdParagraph mdBlockKind = iota
+	mdHeading
+	mdCode
+	mdQuote
+	mdList
+	mdTable
+	mdRule
+)
+
+type mdBlock struct {
+	Kind  mdBlockKind
+	Level int      // heading level
+	Lang  string   // info string of a code fence
+	Lines []string // paragraph, code, quote and table lines
+	Items []mdListItem
+}
+
+type mdListItem struct {
+	Indent  int    // leading spaces
+	Ordered string // "1." for ordered items, "" for bullets
+	Task    int    // 0 no checkbox, 1 open, 2 done
+	Text    string
+}
+
+var (
+	mdHeadingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)(\s+#+)?\s*$`)
+	mdRuleRe      = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_]))*\s*$`)
+	mdListRe      = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	mdTaskRe      = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	mdTableSepRe  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdFenceOpenRe = regexp.MustCompile("^\\s{0,3}(`{3,}|~{3,})\\s*([^`\\s]*)")
+)
+
+// parseMarkdown splits a note into blocks
+func parseMarkdown(src string) []mdBlock {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var blocks []mdBlock
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case mdFenceOpenRe.MatchString(line):
+			match := mdFenceOpenRe.FindStringSubmatch(line)
+			fence := match[1]
+			block := mdBlock{Kind: mdCode, Lang: strings.ToLower(match[2])}
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					i++
+					break
+				}
+				block.Lines = append(block.Lines, lines[i])
+			}
+			blocks = append(blocks, block)
+
+		case mdHeadingRe.MatchString(trimmed):
+			match := mdHeadingRe.FindStringSubmatch(trimmed)
+			blocks = append(blocks, mdBlock{Kind: mdHeading, Level: len(match[1]), Lines: []string{match[2]}})
+			i++
+
+		case isMarkdownRule(line):
+			blocks = append(blocks, mdBlock{Kind: mdRule})
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			block := mdBlock{Kind: mdQuote}
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quoted := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				block.Lines = append(block.Lines, strings.TrimPrefix(quoted, " "))
+			}
+			blocks = append(blocks, block)
+
+		case mdListRe.MatchString(line):
+			block := mdBlock{Kind: mdList}
+			for ; i < len(lines); i++ {
+				if match := mdListRe.FindStringSubmatch(lines[i]); match != nil && !isMarkdownRule(lines[i]) {
+					block.Items = append(block.Items, newListItem(match))
+					continue
+				}
+				// Indented lines continue the previous item
+				next := lines[i]
+				if strings.TrimSpace(next) == "" || !strings.HasPrefix(next, " ") {
+					break
+				}
+				last := &block.Items[len(block.Items)-1]
+				last.Text += " " + strings.TrimSpace(next)
+			}
+			blocks = append(blocks, block)
+
+		case isTableStart(lines, i):
+			block := mdBlock{Kind: mdTable}
+			for ; i < len(lines) && strings.Contains(lines[i], "|"); i++ {
+				block.Lines = append(block.Lines, lines[i])
+			}
+			blocks = append(blocks, block)
+
+		default:
+			block := mdBlock{Kind: mdParagraph}
+			for ; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) == "" || (len(block.Lines) > 0 && startsMarkdownBlock(lines[i])) {
+					break
+				}
+				block.Lines = append(block.Lines, lines[i])
+			}
+			blocks = append(blocks, block)
+		}
+	}
+
+	return blocks
+}
+
+func newListItem(match []string) mdListItem {
+	item := mdListItem{Indent: len(strings.ReplaceAll(match[1], "\t", "    ")), Text: match[3]}
+	if marker := match[2]; marker[0] >= '0' && marker[0] <= '9' {
+		item.Ordered = strings.TrimRight(marker, ".)") + "."
+	}
+	if task := mdTaskRe.FindStringSubmatch(item.Text); task != nil {
+		item.Task = 1
+		if task[1] != " " {
+			item.Task = 2
+		}
+		item.Text = task[2]
+	}
+	return item
+}
+
+// isTableStart reports whether lines[i] is a table header, i.e. it is
+// followed by a |---|---| separator row
+func isTableStart(lines []string, i int) bool {
+	return i+1 < len(lines) &&
+		strings.Contains(lines[i], "|") &&
+		strings.Contains(lines[i+1], "|") &&
+		strings.Contains(lines[i+1], "-") &&
+		mdTableSepRe.MatchString(lines[i+1])
+}
+
+func isMarkdownRule(line string) bool {
+	if !mdRuleRe.MatchString(line) {
+		return false
+	}
+	// All marks must be the same character and there must be three
+	marks := strings.Join(strings.Fields(line), "")
+	return len(marks) >= 3 && strings.Count(marks, marks[:1]) == len(marks)
+}
+
+// startsMarkdownBlock reports whether line interrupts a paragraph
+func startsMarkdownBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return mdFenceOpenRe.MatchString(line) ||
+		mdHeadingRe.MatchString(trimmed) ||
+		isMarkdownRule(line) ||
+		strings.HasPrefix(trimmed, ">") ||
+		mdListRe.MatchString(line)
+}
+
+// === RENDERING ===
+
+var (
+	mdTextStyle  = lipgloss.NewStyle().Foreground(textDim)
+	mdMutedStyle = lipgloss.NewStyle().Foreground(muted)
+	mdCodeStyle  = lipgloss.NewStyle().Foreground(text).Background(bgDark)
+
+	mdHeadingStyles = []lipgloss.Style{
+		lipgloss.NewStyle().Foreground(primary).Bold(true).Underline(true),
+		lipgloss.NewStyle().Foreground(secondary).Bold(true),
+		lipgloss.NewStyle().Foreground(accent).Bold(true),
+		lipgloss.NewStyle().Foreground(textDim).Bold(true),
+	}
+)
+
+// renderMarkdown renders a note for the terminal, wrapped to width cells.
+// selectedCode marks the n-th fenced code block (counting from 1) as the
+// one the copy key acts on; 0 marks none. Words terms look for in the
+// content are highlighted.
+func renderMarkdown(src string, width, selectedCode int, terms []scoreTerm) string {
+	if width < 10 {
+		width = 10
+	}
+
+	var parts []string
+	code := 0
+	for _, block := range parseMarkdown(src) {
+		if block.Kind == mdCode {
+			code++
+			parts = append(parts, renderCodeBlock(block, width, code == selectedCode, terms))
+			continue
+		}
+		parts = append(parts, renderMarkdownBlock(block, width, terms))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// codeBlocks returns the fenced code blocks of a note in order
+func codeBlocks(src string) []mdBlock {
+	var blocks []mdBlock
+	for _, block := range parseMarkdown(src) {
+		if block.Kind == mdCode {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+func renderMarkdownBlock(block mdBlock, width int, terms []scoreTerm) string {
+	switch block.Kind {
+	case mdHeading:
+		style := mdHeadingStyles[min(block.Level, len(mdHeadingStyles))-1]
+		return wrapText(renderInline(block.Lines[0], style, terms), width)
+
+	case mdCode:
+		return renderCodeBlock(block, width, false, terms)
+
+	case mdQuote:
+		bar := lipgloss.NewStyle().Foreground(secondary).Render("│ ")
+		inner := renderMarkdown(strings.Join(block.Lines, "\n"), width-2, 0, terms)
+		lines := strings.Split(inner, "\n")
+		for i := range lines {
+			lines[i] = bar + lines[i]
+		}
+		return strings.Join(lines, "\n")
+
+	case mdList:
+		return renderList(block.Items, width, terms)
+
+	case mdTable:
+		return renderTable(block.Lines, width, terms)
+
+	case mdRule:
+		return mdMutedStyle.Render(strings.Repeat("─", width))
+	}
+
+	return wrapText(renderInline(joinParagraph(block.Lines), mdTextStyle, terms), width)
+}
+
+// joinParagraph joins soft-wrapped lines with spaces, keeping hard breaks
+// (two trailing spaces or a backslash)
+func joinParagraph(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		hard := strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\")
+		b.WriteString(strings.TrimRight(strings.TrimSpace(line), "\\"))
+		if i < len(lines)-1 {
+			if hard {
+				b.WriteString("\n")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+	}
+	return b.String()
+}
+
+func wrapText(s string, width int) string {
+	return lipgloss.NewStyle().Width(width).Render(s)
+}
+
+// renderCodeBlock shows a fenced code block, syntax highlighted when its
+// language is known. Long lines are cut rather than wrapped.
+func renderCodeBlock(block mdBlock, width int, selected bool, terms []scoreTerm) string {
+	var b strings.Builder
+
+	gutter := mdCodeStyle.Render(" ")
+	switch {
+	case selected:
+		gutter = mdCodeStyle.Copy().Foreground(primary).Render("▌")
+		label := "▶ code"
+		if block.Lang != "" {
+			label = "▶ " + block.Lang
+		}
+		b.WriteString(lipgloss.NewStyle().Foreground(primary).Bold(true).Render(label))
+		b.WriteString(mdMutedStyle.Render("  y copies this block"))
+		b.WriteString("\n")
+	case block.Lang != "":
+		b.WriteString(mdMutedStyle.Copy().Italic(true).Render(block.Lang))
+		b.WriteString("\n")
+	}
+
+	lines := make([]string, len(block.Lines))
+	for i, line := range block.Lines {
+		lines[i] = strings.ReplaceAll(line, "\t", "    ")
+	}
+
+	for i, tokens := range highlightCode(block.Lang, lines) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(gutter)
+
+		room := width - 2
+		for _, token := range tokens {
+			if room <= 0 {
+				break
+			}
+			text := token.Text
+			if runewidth.StringWidth(text) > room {
+				text = runewidth.Truncate(text, room, "…")
+			}
+			room -= runewidth.StringWidth(text)
+			b.WriteString(renderMarked(text, tokenStyles[token.Kind], terms))
+		}
+		b.WriteString(mdCodeStyle.Render(strings.Repeat(" ", max(room, 0)+1)))
+	}
+	return b.String()
+}
+
+func renderList(items []mdListItem, width int, terms []scoreTerm) string {
+	bullets := []string{"•", "◦", "▪"}
+	markerStyle := lipgloss.NewStyle().Foreground(accent).Bold(true)
+
+	var lines []string
+	for _, item := range items {
+		level := item.Indent / 2
+		indent := strings.Repeat("  ", min(level, 6))
+
+		marker := item.Ordered
+		if marker == "" {
+			marker = bullets[level%len(bullets)]
+		}
+		switch item.Task {
+		case 1:
+			marker += " ☐"
+		case 2:
+			marker += " " + lipgloss.NewStyle().Foreground(success).Render("☑")
+		}
+		prefix := indent + markerStyle.Render(marker) + " "
+		prefixWidth := lipgloss.Width(prefix)
+
+		textStyle := mdTextStyle
+		if item.Task == 2 {
+			textStyle = mdMutedStyle.Copy().Strikethrough(true)
+		}
+		body := wrapText(renderInline(item.Text, textStyle, terms), max(width-prefixWidth, 10))
+		for i, line := range strings.Split(body, "\n") {
+			if i == 0 {
+				lines = append(lines, prefix+line)
+			} else {
+				lines = append(lines, strings.Repeat(" ", prefixWidth)+line)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// === TABLES ===
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func renderTable(lines []string, width int, terms []scoreTerm) string {
+	header := splitTableRow(lines[0])
+	columns := len(header)
+
+	aligns := make([]lipgloss.Position, columns)
+	for i, spec := range splitTableRow(lines[1]) {
+		if i >= columns {
+			break
+		}
+		switch {
+		case strings.HasPrefix(spec, ":") && strings.HasSuffix(spec, ":"):
+			aligns[i] = lipgloss.Center
+		case strings.HasSuffix(spec, ":"):
+			aligns[i] = lipgloss.Right
+		}
+	}
+
+	rows := [][]string{header}
+	for _, line := range lines[2:] {
+		cells := splitTableRow(line)
+		for len(cells) < columns {
+			cells = append(cells, "")
+		}
+		rows = append(rows, cells[:columns])
+	}
+
+	// Column widths from the plain text, shrunk until the table fits
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], runewidth.StringWidth(plainInline(cell)))
+		}
+	}
+	for {
+		total := columns*3 + 1
+		widest := 0
+		for i, w := range widths {
+			total += w
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if total <= width || widths[widest] <= 3 {
+			break
+		}
+		widths[widest]--
+	}
+
+	border := lipgloss.NewStyle().Foreground(muted)
+	rule := func(left, middle, right string) string {
+		var parts []string
+		for _, w := range widths {
+			parts = append(parts, strings.Repeat("─", w+2))
+		}
+		return border.Render(left + strings.Join(parts, middle) + right)
+	}
+
+	var b strings.Builder
+	b.WriteString(rule("┌", "┬", "┐"))
+	for r, row := range rows {
+		b.WriteString("\n")
+		style := mdTextStyle
+		if r == 0 {
+			style = lipgloss.NewStyle().Foreground(primary).Bold(true)
+		}
+
+		b.WriteString(border.Render("│"))
+		for i, cell := range row {
+			rendered := renderInline(cell, style, terms)
+			if runewidth.StringWidth(plainInline(cell)) > widths[i] {
+				// Too long: fall back to truncated plain text
+				rendered = renderMarked(runewidth.Truncate(plainInline(cell), widths[i], "…"), style, terms)
+			}
+			b.WriteString(" ")
+			b.WriteString(lipgloss.PlaceHorizontal(widths[i], aligns[i], rendered))
+			b.WriteString(" " + border.Render("│"))
+		}
+
+		if r == 0 {
+			b.WriteString("\n")
+			b.WriteString(rule("├", "┼", "┤"))
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(rule("└", "┴", "┘"))
+	return b.String()
+}
+
+// === INLINE ===
+
+type inlineFlags uint8
+
+const (
+	inlineBold inlineFlags = 1 << iota
+	inlineItalic
+	inlineStrike
+	inlineCode
+	inlineLink
+	inlineURL // target of a link, left out of plain text
+)
+
+type inlineSpan struct {
+	text  string
+	flags inlineFlags
+}
+
+// parseInline splits text into runs of equally formatted text
+func parseInline(s string, flags inlineFlags, spans []inlineSpan) []inlineSpan {
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			spans = append(spans, inlineSpan{plain.String(), flags})
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		rest := s[i:]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|~<>", s[i+1]) >= 0:
+			plain.WriteByte(s[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+			fence := rest[:ticks]
+			if end := strings.Index(rest[ticks:], fence); end >= 0 {
+				flush()
+				code := rest[ticks : ticks+end]
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				spans = append(spans, inlineSpan{code, flags | inlineCode})
+				i += ticks + end + ticks
+				continue
+			}
+			plain.WriteString(fence)
+			i += ticks
+			continue
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if inner, n := delimited(s, i, rest[:2]); n > 0 {
+				flush()
+				spans = parseInline(inner, flags|inlineBold, spans)
+				i += n
+				continue
+			}
+
+		case strings.HasPrefix(rest, "~~"):
+			if inner, n := delimited(s, i, "~~"); n > 0 {
+				flush()
+				spans = parseInline(inner, flags|inlineStrike, spans)
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_':
+			if inner, n := delimited(s, i, string(c)); n > 0 {
+				flush()
+				spans = parseInline(inner, flags|inlineItalic, spans)
+				i += n
+				continue
+			}
+
+		case c == '!' && strings.HasPrefix(rest, "!["):
+			if label, url, n := parseLink(rest[1:]); n > 0 {
+				flush()
+				spans = append(spans, inlineSpan{"🖼 " + label, flags | inlineLink})
+				spans = append(spans, inlineSpan{" (" + url + ")", flags | inlineURL})
+				i += 1 + n
+				continue
+			}
+
+		case c == '[':
+			if label, url, n := parseLink(rest); n > 0 {
+				flush()
+				spans = parseInline(label, flags|inlineLink, spans)
+				if url != label && url != "" {
+					spans = append(spans, inlineSpan{" (" + url + ")", flags | inlineURL})
+				}
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(rest, '>'); end > 0 {
+				target := rest[1:end]
+				if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "mailto:") {
+					flush()
+					spans = append(spans, inlineSpan{target, flags | inlineLink})
+					i += end + 1
+					continue
+				}
+			}
+		}
+
+		plain.WriteByte(c)
+		i++
+	}
+
+	flush()
+	return spans
+}
+
+// delimited finds the text between an opening delimiter at s[i] and its
+// closer. It returns the inner text and the length consumed, or 0 if the
+// delimiter doesn't open an emphasis here.
+func delimited(s string, i int, delim string) (string, int) {
+	start := i + len(delim)
+	if start >= len(s) || s[start] == ' ' {
+		return "", 0
+	}
+	// Underscores inside words (snake_case) are not emphasis
+	if delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", 0
+	}
+
+	for j := start + 1; j <= len(s)-len(delim); j++ {
+		if s[j:j+len(delim)] != delim || s[j-1] == ' ' || s[j-1] == '\\' {
+			continue
+		}
+		// A single * must not close on half of a **
+		if len(delim) == 1 && j+1 < len(s) && s[j+1] == delim[0] {
+			j++
+			continue
+		}
+		end := j + len(delim)
+		if delim[0] == '_' && end < len(s) && isWordByte(s[end]) {
+			continue
+		}
+		return s[start:j], end - i
+	}
+	return "", 0
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// parseLink reads "[label](url)" at the start of s
+func parseLink(s string) (label, url string, n int) {
+	closing := strings.Index(s, "](")
+	if closing < 0 || strings.IndexByte(s[:closing], '\n') >= 0 {
+		return "", "", 0
+	}
+	end := strings.IndexByte(s[closing:], ')')
+	if end < 0 {
+		return "", "", 0
+	}
+	url = strings.TrimSpace(s[closing+2 : closing+end])
+	// Drop an optional "title"
+	if space := strings.IndexByte(url, ' '); space >= 0 {
+		url = url[:space]
+	}
+	return s[1:closing], url, closing + end + 1
+}
+
+// renderInline styles inline Markdown on top of base, highlighting the
+// words terms look for
+func renderInline(s string, base lipgloss.Style, terms []scoreTerm) string {
+	var b strings.Builder
+	for _, span := range parseInline(s, 0, nil) {
+		style := base.Copy()
+		if span.flags&inlineBold != 0 {
+			style = style.Bold(true)
+		}
+		if span.flags&inlineItalic != 0 {
+			style = style.Italic(true)
+		}
+		if span.flags&inlineStrike != 0 {
+			style = style.Strikethrough(true)
+		}
+		if span.flags&inlineCode != 0 {
+			style = style.Foreground(warning).Background(bgDark)
+		}
+		if span.flags&inlineLink != 0 {
+			style = style.Foreground(accent).Underline(true)
+		}
+		if span.flags&inlineURL != 0 {
+			style = style.Foreground(muted).Underline(false)
+			b.WriteString(style.Render(span.text))
+			continue
+		}
+		b.WriteString(renderMarked(span.text, style, terms))
+	}
+	return b.String()
+}
+
+// renderMarked renders text in style with the words terms look for in the
+// content picked out
+func renderMarked(text string, style lipgloss.Style, terms []scoreTerm) string {
+	matches := findMatches(text, terms, fieldContent)
+	if len(matches) == 0 {
+		return style.Render(text)
+	}
+
+	mark := style.Copy().Foreground(bg).Background(warning)
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		if match.start > last {
+			b.WriteString(style.Render(text[last:match.start]))
+		}
+		b.WriteString(mark.Render(text[match.start:match.end]))
+		last = match.end
+	}
+	if last < len(text) {
+		b.WriteString(style.Render(text[last:]))
+	}
+	return b.String()
+}
+
+// plainInline drops the inline Markdown syntax, keeping only the text
+func plainInline(s string) string {
+	var b strings.Builder
+	for _, span := range parseInline(s, 0, nil) {
+		if span.flags&inlineURL == 0 {
+			b.WriteString(span.text)
+		}
+	}
+	return b.String()
+}
+
+// === EXCERPTS ===
+
+// markdownExcerpt is the plain text of a note cut to max characters, for
+// note cards
+func markdownExcerpt(src string, max int) string {
+	text := markdownPlain(src)
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max]) + "..."
+}
+
+// markdownPlain is the text of a note without Markdown syntax, collapsed
+// to one line
+func markdownPlain(src string) string {
+	var parts []string
+	for _, block := range parseMarkdown(src) {
+		parts = append(parts, plainBlock(block))
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+func plainBlock(block mdBlock) string {
+	switch block.Kind {
+	case mdCode:
+		return strings.Join(block.Lines, " ")
+	case mdQuote:
+		return markdownPlain(strings.Join(block.Lines, "\n"))
+	case mdList:
+		var items []string
+		for _, item := range block.Items {
+			items = append(items, plainInline(item.Text))
+		}
+		return strings.Join(items, " · ")
+	case mdTable:
+		var cells []string
+		for i, line := range block.Lines {
+			if i != 1 {
+				for _, cell := range splitTableRow(line) {
+					cells = append(cells, plainInline(cell))
+				}
+			}
+		}
+		return strings.Join(cells, " ")
+	case mdRule:
+		return ""
+	}
+	return plainInline(strings.Join(block.Lines, " "))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// showBlocks prints the kinds of blocks with what they hold, e.g.
+// "h2:Title p:text list:0a,>1b" where list items show their nesting, their
+// number and their checkbox state
+func showBlocks(blocks []mdBlock) string {
+	var parts []string
+	for _, block := range blocks {
+		switch block.Kind {
+		case mdHeading:
+			parts = append(parts, fmt.Sprintf("h%d:%s", block.Level, block.Lines[0]))
+		case mdParagraph:
+			parts = append(parts, "p:"+strings.Join(block.Lines, "/"))
+		case mdCode:
+			parts = append(parts, "code("+block.Lang+"):"+strings.Join(block.Lines, "/"))
+		case mdQuote:
+			parts = append(parts, "quote:"+strings.Join(block.Lines, "/"))
+		case mdTable:
+			parts = append(parts, fmt.Sprintf("table:%d", len(block.Lines)))
+		case mdRule:
+			parts = append(parts, "rule")
+		case mdList:
+			var items []string
+			for _, item := range block.Items {
+				items = append(items, fmt.Sprintf("%s%s%d%s", strings.Repeat(">", item.Indent/2), item.Ordered, item.Task, item.Text))
+			}
+			parts = append(parts, "list:"+strings.Join(items, ","))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", ""},
+		{"one\ntwo\n\nthree", "p:one/two p:three"},
+		{"## Title ##\ntext", "h2:Title p:text"},
+		{"#hashtag", "p:#hashtag"},
+		{"text\n# Heading", "p:text h1:Heading"},
+		{"```Go\nfunc main() {\n\n}\n```\nafter", "code(go):func main() {//} p:after"},
+		{"~~~\nno closing fence", "code():no closing fence"},
+		{"> quoted\n>more\ntext", "quote:quoted/more p:text"},
+		{"- a\n  continued\n* b", "list:0a continued,0b"},
+		{"1. one\n2) two", "list:1.0one,2.0two"},
+		{"- [ ] open\n- [x] done\n  - nested", "list:1open,2done,>0nested"},
+		{"---\n* * *\n--", "rule rule p:--"},
+		{"a | b\n--|--\n1 | 2\n\nafter", "table:3 p:after"},
+		{"a | b\nno separator", "p:a | b/no separator"},
+		{"line\r\nwindows", "p:line/windows"},
+	}
+	for _, tt := range tests {
+		if got := showBlocks(parseMarkdown(tt.src)); got != tt.want {
+			t.Errorf("parseMarkdown(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestPlainInline(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"plain", "plain"},
+		{"**bold** and *italic* and ~~gone~~", "bold and italic and gone"},
+		{"__bold__ _italic_", "bold italic"},
+		{"snake_case_name", "snake_case_name"},
+		{"2 * 3 * 4", "2 * 3 * 4"},
+		{"`**code**` and `` a`b ``", "**code** and a`b"},
+		{"unclosed `tick", "unclosed `tick"},
+		{`\*not italic\*`, "*not italic*"},
+		{"[label](https://example.com \"title\")", "label"},
+		{"![photo](cat.png)", "🖼 photo"},
+		{"<https://example.com> <not a link>", "https://example.com <not a link>"},
+		{"Zażółć **gęślą** jaźń", "Zażółć gęślą jaźń"},
+	}
+	for _, tt := range tests {
+		if got := plainInline(tt.src); got != tt.want {
+			t.Errorf("plainInline(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownExcerpt(t *testing.T) {
+	tests := []struct {
+		src  string
+		max  int
+		want string
+	}{
+		{"# Title\n\nSome **bold** text", 100, "Title Some bold text"},
+		{"- a\n- b\n\n> quoted *text*", 100, "a · b quoted text"},
+		{"| h1 | h2 |\n|----|----|\n| c1 | c2 |", 100, "h1 h2 c1 c2"},
+		{"```\ncode\n```", 100, "code"},
+		{"Zażółć gęślą jaźń", 6, "Zażółć..."},
+		{"exactly", 7, "exactly"},
+	}
+	for _, tt := range tests {
+		if got := markdownExcerpt(tt.src, tt.max); got != tt.want {
+			t.Errorf("markdownExcerpt(%q, %d) = %q, want %q", tt.src, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdownWidth(t *testing.T) {
+	src := "# A heading long enough to wrap around\n\n" +
+		"A paragraph with **bold** words and a [link](https://example.com/a/very/long/path) that wraps.\n\n" +
+		"- a list item that goes on for a while\n  - nested\n\n" +
+		"> a quote that is also rather long\n\n" +
+		"| name | value |\n|---|---|\n| zażółć gęślą jaźń | 日本語のテキスト |\n\n" +
+		"```go\nfunc main() { fmt.Println(\"a line of code wider than the view\") }\n```"
+
+	// Very narrow views overflow on purpose: list text and table columns
+	// keep a minimum width so they stay readable
+	for _, width := range []int{30, 40, 60} {
+		rendered := renderMarkdown(src, width, 1, nil)
+		for _, line := range strings.Split(stripANSI(rendered), "\n") {
+			if w := runewidth.StringWidth(line); w > width {
+				t.Errorf("width %d: line %q is %d cells wide", width, line, w)
+			}
+		}
+	}
+}
@@ -1,9 +1,16 @@
 package main
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"sort"
 	"strings"
@@ -11,66 +18,323 @@ import (
 )
 
 type Note struct {
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Tags      []string  `json:"tags"`
-	Timestamp time.Time `json:"timestamp"`
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Tags      []string   `json:"tags"`
+	Created   time.Time  `json:"created"`
+	Modified  time.Time  `json:"modified"`
+	Pinned    bool       `json:"pinned,omitempty"`
+	Deleted   *time.Time `json:"deleted,omitempty"`   // when it was moved to the trash
+	Revisions []Revision `json:"revisions,omitempty"` // oldest first
+}
+
+// UnmarshalJSON also reads notes saved before Created/Modified existed,
+// which only had a "timestamp" creation time.
+func (note *Note) UnmarshalJSON(data []byte) error {
+	type plainNote Note
+	aux := struct {
+		*plainNote
+		Timestamp time.Time `json:"timestamp"`
+	}{plainNote: (*plainNote)(note)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if note.Created.IsZero() {
+		note.Created = aux.Timestamp
+	}
+	if note.Modified.IsZero() {
+		note.Modified = note.Created
+	}
+	return nil
 }
 
 type Notebook struct {
 	Notes    []Note
+	Trash    []Note
 	filename string
 	password string
+	salt     []byte
+	kdf      kdfParams
+	key      []byte
+
+	retention        retentionPolicy
+	trashRetention   time.Duration
+	autosaveInterval time.Duration // 0 turns autosave off
+	settingsLoaded   bool          // the settings above were read from the file
+
+	// legacyFile is set while the file on disk is still in the 1.0 format.
+	// It is never kept as a backup generation, so its weak encryption
+	// doesn't outlive the migration.
+	legacyFile bool
+
+	index *searchIndex // nil until loaded or first searched
+
+	// revision counts in-memory changes, savedRevision is the revision
+	// that was last written to disk.
+	revision      int
+	savedRevision int
 }
 
 func NewNote(title, content string, tags []string) *Note {
+	now := time.Now()
 	return &Note{
-		Title:     title,
-		Content:   content,
-		Tags:      tags,
-		Timestamp: time.Now(),
+		ID:       newNoteID(),
+		Title:    title,
+		Content:  content,
+		Tags:     tags,
+		Created:  now,
+		Modified: now,
+	}
+}
+
+// newNoteID returns a random identifier that stays with the note for its
+// whole life, whatever order the notes are shown in.
+func newNoteID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		// crypto/rand doesn't fail on supported platforms; fall back to
+		// the clock rather than leaving the note without an ID
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
 	}
+	return hex.EncodeToString(id)
 }
 
 func NewNotebook(filename, password string) *Notebook {
 	return &Notebook{
-		Notes:    []Note{},
-		filename: filename,
-		password: password,
+		Notes:            []Note{},
+		filename:         filename,
+		password:         password,
+		retention:        defaultRetention,
+		trashRetention:   defaultTrashRetention,
+		autosaveInterval: defaultAutosaveInterval,
 	}
 }
 
 func (n *Notebook) AddNote(note *Note) {
+	if note.ID == "" {
+		note.ID = newNoteID()
+	}
 	n.Notes = append(n.Notes, *note)
+	n.reindex(note.ID)
+	n.markDirty()
 }
 
-func (n *Notebook) DeleteNote(index int) {
-	if index >= 0 && index < len(n.Notes) {
-		n.Notes = append(n.Notes[:index], n.Notes[index+1:]...)
+// UpdateNote replaces the title, content and tags of a note. The version
+// being replaced is appended to the note's revisions, the creation time is
+// kept and the modification time is set to now.
+func (n *Notebook) UpdateNote(id string, title, content string, tags []string) bool {
+	index := n.indexOf(id)
+	if index < 0 {
+		return false
 	}
+
+	note := &n.Notes[index]
+	if note.Title == title && note.Content == content && equalTags(note.Tags, tags) {
+		return true
+	}
+
+	note.Revisions = append(note.Revisions, newRevision(*note))
+	note.Title = title
+	note.Content = content
+	note.Tags = tags
+	note.Modified = time.Now()
+	n.reindex(id)
+	n.markDirty()
+	return true
 }
 
-func (n *Notebook) Search(query string) []Note {
-	var results []Note
-	query = strings.ToLower(query)
+// RestoreRevision makes an older version of a note current again. It goes
+// through UpdateNote, so the version being replaced is kept as well.
+func (n *Notebook) RestoreRevision(id string, revision int) error {
+	note, ok := n.GetNote(id)
+	if !ok {
+		return fmt.Errorf("note no longer exists")
+	}
+	if revision < 0 || revision >= len(note.Revisions) {
+		return fmt.Errorf("no such revision")
+	}
 
-	for _, note := range n.Notes {
-		if strings.Contains(strings.ToLower(note.Title), query) ||
-			strings.Contains(strings.ToLower(note.Content), query) {
-			results = append(results, note)
-			continue
+	old := note.Revisions[revision]
+	content, err := old.Text()
+	if err != nil {
+		return err
+	}
+	n.UpdateNote(id, old.Title, content, old.Tags)
+	return nil
+}
+
+// GetNote looks a note up by ID
+func (n *Notebook) GetNote(id string) (Note, bool) {
+	if index := n.indexOf(id); index >= 0 {
+		return n.Notes[index], true
+	}
+	return Note{}, false
+}
+
+// TogglePin pins or unpins a note; pinned notes are listed first
+func (n *Notebook) TogglePin(id string) bool {
+	index := n.indexOf(id)
+	if index < 0 {
+		return false
+	}
+	n.Notes[index].Pinned = !n.Notes[index].Pinned
+	n.markDirty()
+	return true
+}
+
+// CopyNoteTo saves a copy of a note, history included, into another
+// notebook. The copy gets a new ID, so the same note can be copied twice.
+func (n *Notebook) CopyNoteTo(id string, dst *Notebook) error {
+	note, ok := n.GetNote(id)
+	if !ok {
+		return fmt.Errorf("note no longer exists")
+	}
+
+	note = note.clone()
+	note.ID = newNoteID()
+	return dst.addAndSave(note)
+}
+
+// MoveNoteTo moves a note into another notebook. The other notebook is
+// saved before the note is removed from this one, so a failed save leaves
+// the note where it was instead of losing it.
+func (n *Notebook) MoveNoteTo(id string, dst *Notebook) error {
+	index := n.indexOf(id)
+	if index < 0 {
+		return fmt.Errorf("note no longer exists")
+	}
+
+	note := n.Notes[index].clone()
+	if dst.indexOf(note.ID) >= 0 || dst.trashIndexOf(note.ID) >= 0 {
+		note.ID = newNoteID()
+	}
+	if err := dst.addAndSave(note); err != nil {
+		return err
+	}
+
+	n.Notes = append(n.Notes[:index], n.Notes[index+1:]...)
+	n.reindex(id)
+	n.markDirty()
+	return nil
+}
+
+// addAndSave adds a note and saves. If the save fails the notebook is put
+// back as it was: the note is dropped from the notes and the index, and the
+// revision is restored so it isn't left marked as changed.
+func (n *Notebook) addAndSave(note Note) error {
+	revision := n.revision
+	n.AddNote(&note)
+	if err := n.Save(); err != nil {
+		n.Notes = n.Notes[:len(n.Notes)-1]
+		if n.index != nil {
+			n.index.remove(note.ID)
 		}
-		
-		// Search in tags
-		for _, tag := range note.Tags {
-			if strings.Contains(strings.ToLower(tag), query) {
-				results = append(results, note)
-				break
+		n.revision = revision
+		return err
+	}
+	return nil
+}
+
+// clone copies a note so that it shares no slices with the original
+func (note Note) clone() Note {
+	if note.Tags != nil {
+		note.Tags = append([]string{}, note.Tags...)
+	}
+	if note.Revisions != nil {
+		note.Revisions = append([]Revision{}, note.Revisions...)
+	}
+	return note
+}
+
+func (n *Notebook) indexOf(id string) int {
+	if id == "" {
+		return -1
+	}
+	for i := range n.Notes {
+		if n.Notes[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ensureIDs gives notes from files written before IDs existed one
+func (n *Notebook) ensureIDs() {
+	seen := make(map[string]bool)
+	for _, notes := range [][]Note{n.Notes, n.Trash} {
+		for i := range notes {
+			if notes[i].ID == "" || seen[notes[i].ID] {
+				notes[i].ID = newNoteID()
+				n.markDirty()
 			}
+			seen[notes[i].ID] = true
 		}
 	}
+}
+
+// markDirty records an in-memory change that hasn't been saved yet
+func (n *Notebook) markDirty() {
+	n.revision++
+}
 
-	return results
+// IsDirty reports whether there are changes that haven't been saved
+func (n *Notebook) IsDirty() bool {
+	return n.revision != n.savedRevision
+}
+
+// Revision identifies the current in-memory state; it changes on every edit
+func (n *Notebook) Revision() int {
+	return n.revision
+}
+
+// searchResult is a note found by Search with its relevance score
+type searchResult struct {
+	Note
+	Score float64
+}
+
+// Search returns the notes matching a query, best match first; see
+// query.go for the syntax
+func (n *Notebook) Search(query string) ([]searchResult, error) {
+	parsed, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if n.index == nil {
+		n.index = newSearchIndex(n.Notes)
+	}
+
+	matches := parsed.eval(n.index)
+	terms := parsed.terms()
+
+	var results []searchResult
+	for _, note := range n.Notes {
+		if matches[note.ID] {
+			results = append(results, searchResult{Note: note, Score: n.index.score(note.ID, terms)})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Created.After(results[j].Created)
+	})
+	return results, nil
+}
+
+// reindex brings the search index up to date with one note, whether it
+// was added, changed or removed
+func (n *Notebook) reindex(id string) {
+	if n.index == nil {
+		return
+	}
+	if index := n.indexOf(id); index >= 0 {
+		n.index.add(&n.Notes[index])
+	} else {
+		n.index.remove(id)
+	}
 }
 
 func (n *Notebook) CountWords() int {
@@ -104,11 +368,11 @@ func (n *Notebook) GetTagCloud() map[string]int {
 func (n *Notebook) GetRecentNotes(count int) []Note {
 	sorted := make([]Note, len(n.Notes))
 	copy(sorted, n.Notes)
-	
+
 	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].Timestamp.After(sorted[j].Timestamp)
+		return sorted[i].Modified.After(sorted[j].Modified)
 	})
-	
+
 	if len(sorted) > count {
 		return sorted[:count]
 	}
@@ -119,7 +383,7 @@ func (n *Notebook) SortNotes(mode sortMode) {
 	switch mode {
 	case sortByDate:
 		sort.Slice(n.Notes, func(i, j int) bool {
-			return n.Notes[i].Timestamp.After(n.Notes[j].Timestamp)
+			return n.Notes[i].Created.After(n.Notes[j].Created)
 		})
 	case sortByTitle:
 		sort.Slice(n.Notes, func(i, j int) bool {
@@ -141,11 +405,11 @@ func (n *Notebook) SortNotes(mode sortMode) {
 func (n *Notebook) GetSortedNotes(mode sortMode) []Note {
 	sorted := make([]Note, len(n.Notes))
 	copy(sorted, n.Notes)
-	
+
 	switch mode {
 	case sortByDate:
 		sort.Slice(sorted, func(i, j int) bool {
-			return sorted[i].Timestamp.After(sorted[j].Timestamp)
+			return sorted[i].Created.After(sorted[j].Created)
 		})
 	case sortByTitle:
 		sort.Slice(sorted, func(i, j int) bool {
@@ -162,119 +426,371 @@ func (n *Notebook) GetSortedNotes(mode sortMode) []Note {
 			return sorted[i].Tags[0] < sorted[j].Tags[0]
 		})
 	}
-	
+
+	// Pinned notes stay on top whatever the sort order
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Pinned && !sorted[j].Pinned
+	})
+
 	return sorted
 }
 
-func (n *Notebook) Save() error {
-	// Serialize notes to JSON
-	data, err := json.Marshal(n.Notes)
-	if err != nil {
-		return err
+// notebookData is the JSON document encrypted inside an .alpaka file
+type notebookData struct {
+	Notes    []Note            `json:"notes"`
+	Trash    []Note            `json:"trash,omitempty"`
+	Settings *notebookSettings `json:"settings,omitempty"`
+}
+
+// notebookSettings are the choices made on the settings screen that belong
+// to the notebook. Files from before they were saved have none.
+type notebookSettings struct {
+	TrashRetention   time.Duration    `json:"trashRetention"` // 0 keeps deleted notes until emptied
+	BackupRetention  *retentionPolicy `json:"backupRetention,omitempty"`
+	AutosaveInterval *time.Duration   `json:"autosaveInterval,omitempty"` // 0 turns autosave off
+}
+
+// Errors returned by LoadNotebook. Callers should use errors.Is, the
+// returned errors usually carry extra detail.
+var (
+	ErrNotExist           = errors.New("notebook does not exist")
+	ErrBadPassword        = errors.New("invalid password")
+	ErrCorrupt            = errors.New("notebook file is corrupt")
+	ErrUnsupportedVersion = errors.New("unsupported notebook version")
+)
+
+// ErrOldBackups is returned by ChangePassword when the password was changed
+// but some backup generations are still encrypted with the old one
+var ErrOldBackups = errors.New("backups under the old password remain")
+
+const (
+	fileMagic      = "ALPAKA"
+	encryptedMark  = "---ENCRYPTED---\n"
+	formatVersion1 = "1.0"
+	formatVersion2 = "2.0"
+)
+
+// fileHeader holds the KEY:VALUE lines between the magic line and the
+// encrypted marker.
+type fileHeader struct {
+	Version string
+	Fields  map[string]string
+}
+
+// parseHeader splits an .alpaka file into its header and the payload that
+// follows the encrypted marker. raw is the exact header bytes including the
+// marker, which v2 files authenticate as GCM additional data.
+func parseHeader(data []byte) (header fileHeader, raw []byte, payload []byte, err error) {
+	markerIdx := strings.Index(string(data), encryptedMark)
+	if markerIdx == -1 {
+		return header, nil, nil, fmt.Errorf("missing encrypted data")
 	}
 
-	// Encrypt
-	encrypted := encrypt(data, n.password)
+	raw = data[:markerIdx+len(encryptedMark)]
+	payload = data[markerIdx+len(encryptedMark):]
 
-	// Create file header
-	passwordHash := hashPassword(n.password)
-	header := fmt.Sprintf("ALPAKA\nVERSION:1.0\nHASH:%s\n---ENCRYPTED---\n", passwordHash)
+	lines := strings.Split(strings.TrimSuffix(string(data[:markerIdx]), "\n"), "\n")
+	if len(lines) < 2 || lines[0] != fileMagic {
+		return header, nil, nil, fmt.Errorf("invalid file format")
+	}
+
+	header.Fields = make(map[string]string)
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return header, nil, nil, fmt.Errorf("invalid header line %q", line)
+		}
+		header.Fields[key] = value
+	}
+	header.Version = header.Fields["VERSION"]
+
+	return header, raw, payload, nil
+}
 
-	// Write to file
-	file, err := os.Create(n.filename)
+func (n *Notebook) Save() error {
+	return n.save(!n.legacyFile)
+}
+
+// save writes the notebook, keeping the file it replaces as a backup
+// generation if backup is set
+func (n *Notebook) save(backup bool) error {
+	data, err := n.encode()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	if _, err := file.WriteString(header); err != nil {
-		return err
+	if err := ensureNotebookDir(n.filename); err != nil {
+		return fmt.Errorf("save failed: %w", err)
 	}
 
-	if _, err := file.Write(encrypted); err != nil {
-		return err
+	// Keep the current file as a backup generation before replacing it
+	var generation string
+	if backup {
+		if generation, err = n.backupCurrent(); err != nil {
+			return fmt.Errorf("backup failed: %w", err)
+		}
+	}
+
+	// Replace the file atomically so a crash can't destroy the notebook
+	if err := writeFileAtomic(n.filename, data); err != nil {
+		if generation != "" {
+			os.Remove(generation)
+		}
+		return fmt.Errorf("save failed: %w", err)
 	}
 
+	n.savedRevision = n.revision
+	n.legacyFile = false
+
+	// The save itself succeeded; a failed prune only leaves extra backups
+	_ = n.pruneBackups(time.Now())
+
 	return nil
 }
 
+// encode returns the encrypted file contents for the notebook, deriving
+// the key first if there is none yet
+func (n *Notebook) encode() ([]byte, error) {
+	// Serialize notes to JSON
+	data, err := json.Marshal(notebookData{
+		Notes: n.Notes,
+		Trash: n.Trash,
+		Settings: &notebookSettings{
+			TrashRetention:   n.trashRetention,
+			BackupRetention:  &n.retention,
+			AutosaveInterval: &n.autosaveInterval,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Derive the key once per notebook; every save reuses the salt
+	if n.key == nil {
+		salt, err := newSalt()
+		if err != nil {
+			return nil, err
+		}
+		n.salt = salt
+		n.kdf = defaultKDFParams
+		n.key = deriveKey(n.password, n.salt, n.kdf)
+	}
+
+	// Create file header. CHECK is derived from the Argon2id key, so it is
+	// as slow to brute-force as the key itself.
+	header := fmt.Sprintf("%s\nVERSION:%s\nKDF:argon2id\nKDFPARAMS:%s\nSALT:%s\nCHECK:%s\nCIPHER:aes-256-gcm\n%s",
+		fileMagic, formatVersion2, n.kdf,
+		base64.StdEncoding.EncodeToString(n.salt),
+		base64.StdEncoding.EncodeToString(passwordCheck(n.key)),
+		encryptedMark)
+
+	// Encrypt, binding the header so it can't be altered either
+	encrypted, err := seal(n.key, data, []byte(header))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(header), encrypted...), nil
+}
+
 func LoadNotebook(filename, password string) (*Notebook, error) {
-	// Read file
-	data, err := os.ReadFile(filename)
+	notebook, version, err := readNotebook(filename, password, nil)
 	if err != nil {
 		return nil, err
 	}
+	notebook.PurgeExpired(time.Now())
+	notebook.index = newSearchIndex(notebook.Notes)
 
-	// Parse header
-	lines := strings.Split(string(data), "\n")
-	if len(lines) < 4 || lines[0] != "ALPAKA" {
-		return nil, fmt.Errorf("invalid file format")
+	// Rewrite legacy files in the current format. A failure here is not
+	// fatal: the notes were read and the next save tries again.
+	if version == formatVersion1 {
+		_ = notebook.Save()
 	}
 
-	// Extract password hash
-	hashLine := lines[2]
-	if !strings.HasPrefix(hashLine, "HASH:") {
-		return nil, fmt.Errorf("missing password hash")
+	return notebook, nil
+}
+
+// readNotebook decrypts filename and reports the format version it was
+// stored in. If known uses the same password, salt and KDF parameters its
+// derived key is reused instead of running Argon2id again, which keeps
+// opening many backups of one notebook fast.
+func readNotebook(filename, password string, known *Notebook) (*Notebook, string, error) {
+	// Read file
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, "", fmt.Errorf("%w: %s", ErrNotExist, filename)
+		}
+		return nil, "", err
 	}
-	storedHash := hashLine[5:]
 
-	// Verify password
-	if hashPassword(password) != storedHash {
-		return nil, fmt.Errorf("invalid password")
+	header, raw, encrypted, err := parseHeader(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrCorrupt, err)
 	}
 
-	// Find encrypted data start
-	encryptedStart := strings.Index(string(data), "---ENCRYPTED---\n") + 16
-	if encryptedStart == 15 {
-		return nil, fmt.Errorf("missing encrypted data")
+	notebook := &Notebook{
+		filename:         filename,
+		password:         password,
+		retention:        defaultRetention,
+		trashRetention:   defaultTrashRetention,
+		autosaveInterval: defaultAutosaveInterval,
 	}
 
-	encrypted := data[encryptedStart:]
+	var decrypted []byte
+	switch header.Version {
+	case formatVersion1:
+		// 1.0 files can only be checked against their unsalted hash
+		storedHash, ok := header.Fields["HASH"]
+		if !ok {
+			return nil, "", fmt.Errorf("%w: missing password hash", ErrCorrupt)
+		}
+		if legacyHashPassword(password) != storedHash {
+			return nil, "", ErrBadPassword
+		}
+		decrypted = legacyDecrypt(encrypted, password)
 
-	// Decrypt
-	decrypted := decrypt(encrypted, password)
+	case formatVersion2:
+		if header.Fields["KDF"] != "argon2id" || header.Fields["CIPHER"] != "aes-256-gcm" {
+			return nil, "", fmt.Errorf("%w: kdf %q, cipher %q",
+				ErrUnsupportedVersion, header.Fields["KDF"], header.Fields["CIPHER"])
+		}
+		params, err := parseKDFParams(header.Fields["KDFPARAMS"])
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		salt, err := base64.StdEncoding.DecodeString(header.Fields["SALT"])
+		if err != nil || len(salt) == 0 {
+			return nil, "", fmt.Errorf("%w: invalid salt", ErrCorrupt)
+		}
 
-	// Deserialize JSON
-	var notes []Note
-	if err := json.Unmarshal(decrypted, &notes); err != nil {
-		return nil, fmt.Errorf("decryption error: %v", err)
+		notebook.salt = salt
+		notebook.kdf = params
+		if known != nil && known.key != nil && known.password == password &&
+			known.kdf == params && bytes.Equal(known.salt, salt) {
+			notebook.key = known.key
+		} else {
+			notebook.key = deriveKey(password, salt, params)
+		}
+
+		// With a CHECK value a wrong password is told apart from a
+		// damaged file; without one, failed authentication is all we have.
+		check, hasCheck := header.Fields["CHECK"]
+		if hasCheck {
+			stored, err := base64.StdEncoding.DecodeString(check)
+			if err != nil {
+				return nil, "", fmt.Errorf("%w: invalid password check", ErrCorrupt)
+			}
+			if !hmac.Equal(stored, passwordCheck(notebook.key)) {
+				return nil, "", ErrBadPassword
+			}
+		}
+
+		decrypted, err = open(notebook.key, encrypted, raw)
+		if err != nil {
+			if !hasCheck {
+				return nil, "", ErrBadPassword
+			}
+			return nil, "", fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+
+	default:
+		return nil, "", fmt.Errorf("%w: %q", ErrUnsupportedVersion, header.Version)
 	}
 
-	return &Notebook{
-		Notes:    notes,
-		filename: filename,
-		password: password,
-	}, nil
+	// Deserialize JSON. Older files hold just the array of notes.
+	var payload notebookData
+	if len(decrypted) > 0 && decrypted[0] == '[' {
+		err = json.Unmarshal(decrypted, &payload.Notes)
+	} else {
+		err = json.Unmarshal(decrypted, &payload)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	notebook.Notes = payload.Notes
+	notebook.Trash = payload.Trash
+	notebook.legacyFile = header.Version == formatVersion1
+	if payload.Settings != nil {
+		notebook.trashRetention = payload.Settings.TrashRetention
+		notebook.settingsLoaded = true
+		if payload.Settings.BackupRetention != nil {
+			notebook.retention = *payload.Settings.BackupRetention
+		}
+		if payload.Settings.AutosaveInterval != nil {
+			notebook.autosaveInterval = *payload.Settings.AutosaveInterval
+		}
+	}
+	notebook.ensureIDs()
+
+	return notebook, header.Version, nil
 }
 
-// Simple XOR encryption (dla demonstracji - w produkcji użyj AES)
-func encrypt(data []byte, password string) []byte {
-	key := deriveKey(password, len(data)+256)
-	result := make([]byte, len(data))
+// ChangePassword re-encrypts the notebook under newPassword with a fresh
+// salt. The file on disk is the source of truth for the old password. Until
+// the re-encrypted file has been read back successfully, a copy of the old
+// one is kept next to it and put back if anything goes wrong.
+//
+// The old file doesn't become a backup generation. Once the new file is
+// verified, the existing generations are re-encrypted too and those that
+// don't open with the old password are deleted, so nothing on disk can
+// still be read with it. It returns how many generations were re-encrypted
+// and deleted.
+func (n *Notebook) ChangePassword(oldPassword, newPassword string) (rekeyed, removed int, err error) {
+	if newPassword == "" {
+		return 0, 0, fmt.Errorf("new password cannot be empty")
+	}
 
-	for i := 0; i < len(data); i++ {
-		result[i] = data[i] ^ key[i%len(key)]
+	_, statErr := os.Stat(n.filename)
+	exists := statErr == nil
+	if exists {
+		if _, _, err := readNotebook(n.filename, oldPassword, n); err != nil {
+			return 0, 0, err
+		}
+	} else if subtle.ConstantTimeCompare([]byte(oldPassword), []byte(n.password)) != 1 {
+		return 0, 0, ErrBadPassword
 	}
 
-	return result
-}
+	safetyCopy := n.filename + ".rekey"
+	if exists {
+		os.Remove(safetyCopy)
+		if err := copyFile(n.filename, safetyCopy); err != nil {
+			return 0, 0, fmt.Errorf("backup failed: %w", err)
+		}
+	}
 
-func decrypt(data []byte, password string) []byte {
-	// XOR is symmetric
-	return encrypt(data, password)
-}
+	previous := &Notebook{password: n.password, salt: n.salt, kdf: n.kdf, key: n.key}
+	rollback := func(cause error) (int, int, error) {
+		n.password, n.salt, n.kdf, n.key = previous.password, previous.salt, previous.kdf, previous.key
+		if exists {
+			if err := os.Rename(safetyCopy, n.filename); err != nil {
+				return 0, 0, fmt.Errorf("%v (old file kept at %s: %v)", cause, safetyCopy, err)
+			}
+		}
+		return 0, 0, cause
+	}
 
-func deriveKey(password string, length int) []byte {
-	key := make([]byte, length)
+	// A nil key makes Save pick a new salt and derive a new key
+	n.password = newPassword
+	n.key = nil
+	if err := n.save(false); err != nil {
+		return rollback(err)
+	}
 
-	for i := 0; i < length; i++ {
-		key[i] = password[i%len(password)] ^ byte(i&0xFF)
+	check, _, err := readNotebook(n.filename, newPassword, nil)
+	if err != nil {
+		return rollback(fmt.Errorf("verifying re-encrypted notebook failed: %w", err))
+	}
+	if len(check.Notes) != len(n.Notes) {
+		return rollback(fmt.Errorf("verifying re-encrypted notebook failed: note count mismatch"))
 	}
 
-	return key
-}
+	if exists {
+		os.Remove(safetyCopy)
+	}
 
-func hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return fmt.Sprintf("%x", hash)
+	rekeyed, removed, err = n.rekeyBackups(previous, oldPassword)
+	if err != nil {
+		return rekeyed, removed, fmt.Errorf("%w: %v", ErrOldBackups, err)
+	}
+	return rekeyed, removed, nil
 }
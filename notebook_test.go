@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func saveTestNotebook(t *testing.T, password string) (string, *Notebook) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notes.alpaka")
+	notebook := NewNotebook(path, password)
+	notebook.AddNote(NewNote("Shopping", "Buy milk", []string{"home"}))
+	notebook.AddNote(NewNote("Zażółć", "gęślą jaźń", nil))
+	if err := notebook.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return path, notebook
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path, saved := saveTestNotebook(t, "secret")
+
+	loaded, err := LoadNotebook(path, "secret")
+	if err != nil {
+		t.Fatalf("LoadNotebook: %v", err)
+	}
+	if len(loaded.Notes) != len(saved.Notes) {
+		t.Fatalf("got %d notes, want %d", len(loaded.Notes), len(saved.Notes))
+	}
+	for i, note := range loaded.Notes {
+		want := saved.Notes[i]
+		if note.ID != want.ID || note.Title != want.Title || note.Content != want.Content {
+			t.Errorf("note %d = %q/%q, want %q/%q", i, note.ID, note.Title, want.ID, want.Title)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("Buy milk")) {
+		t.Error("note content is stored in plain text")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	path, _ := saveTestNotebook(t, "secret")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marker := bytes.Index(data, []byte(encryptedMark)) + len(encryptedMark)
+
+	tests := []struct {
+		name     string
+		password string
+		edit     func([]byte) []byte
+		want     error
+	}{
+		{"wrong password", "wrong", nil, ErrBadPassword},
+		{"added header line", "secret", func(d []byte) []byte {
+			return bytes.Replace(d, []byte("CIPHER:"), []byte("NOTE:x\nCIPHER:"), 1)
+		}, ErrCorrupt},
+		{"flipped payload byte", "secret", func(d []byte) []byte {
+			d[marker+20] ^= 1
+			return d
+		}, ErrCorrupt},
+		{"huge kdf memory", "secret", func(d []byte) []byte {
+			return bytes.Replace(d, []byte("m=65536"), []byte("m=4294967295"), 1)
+		}, ErrCorrupt},
+		{"unknown cipher", "secret", func(d []byte) []byte {
+			return bytes.Replace(d, []byte("aes-256-gcm"), []byte("rot13"), 1)
+		}, ErrUnsupportedVersion},
+		{"unknown version", "secret", func(d []byte) []byte {
+			return bytes.Replace(d, []byte("VERSION:2.0"), []byte("VERSION:9.0"), 1)
+		}, ErrUnsupportedVersion},
+		{"no marker", "secret", func(d []byte) []byte {
+			return bytes.Replace(d, []byte(encryptedMark), nil, 1)
+		}, ErrCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := path
+			if tt.edit != nil {
+				file = filepath.Join(t.TempDir(), "edited.alpaka")
+				edited := tt.edit(append([]byte(nil), data...))
+				if err := os.WriteFile(file, edited, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := LoadNotebook(file, tt.password); !errors.Is(err, tt.want) {
+				t.Errorf("LoadNotebook = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadNotebook(filepath.Join(t.TempDir(), "missing.alpaka"), "secret"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("missing file: LoadNotebook = %v, want %v", err, ErrNotExist)
+	}
+}
+
+func TestLoadLegacyNotebook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.alpaka")
+	payload := `[{"title":"Old note","content":"from 1.0","tags":["legacy"],"timestamp":"2024-05-01T10:00:00Z"}]`
+	header := fileMagic + "\nVERSION:" + formatVersion1 + "\nHASH:" + legacyHashPassword("secret") + "\n" + encryptedMark
+	// The 1.0 cipher is a plain XOR, so decrypting also encrypts
+	file := append([]byte(header), legacyDecrypt([]byte(payload), "secret")...)
+	if err := os.WriteFile(path, file, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadNotebook(path, "wrong"); !errors.Is(err, ErrBadPassword) {
+		t.Errorf("wrong password: LoadNotebook = %v, want %v", err, ErrBadPassword)
+	}
+
+	notebook, err := LoadNotebook(path, "secret")
+	if err != nil {
+		t.Fatalf("LoadNotebook: %v", err)
+	}
+	if len(notebook.Notes) != 1 {
+		t.Fatalf("got %d notes, want 1", len(notebook.Notes))
+	}
+	note := notebook.Notes[0]
+	if note.Title != "Old note" || note.ID == "" || note.Created.IsZero() || !note.Modified.Equal(note.Created) {
+		t.Errorf("legacy note read as %+v", note)
+	}
+
+	// Loading migrates the file to the current format
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "VERSION:"+formatVersion2) {
+		t.Errorf("file was not migrated:\n%s", data[:bytes.Index(data, []byte(encryptedMark))])
+	}
+	// The 1.0 file must not survive as a backup
+	if generations, _ := notebook.backupPaths(); len(generations) != 0 {
+		t.Errorf("migration kept %d backup generations", len(generations))
+	}
+	migrated, err := LoadNotebook(path, "secret")
+	if err != nil {
+		t.Fatalf("LoadNotebook after migration: %v", err)
+	}
+	if got, _ := json.Marshal(migrated.Notes); !bytes.Contains(got, []byte("from 1.0")) {
+		t.Errorf("migrated notes = %s", got)
+	}
+}
+
+func TestParseKDFParams(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"t=3,m=65536,p=4", true},
+		{"t=10,m=4194304,p=255", true},
+		{"t=0,m=65536,p=4", false},
+		{"t=11,m=65536,p=4", false},
+		{"t=3,m=4194305,p=4", false},
+		{"t=3,m=65536,p=256", false},
+		{"t=3,m=65536", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if _, err := parseKDFParams(tt.in); (err == nil) != tt.ok {
+			t.Errorf("parseKDFParams(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+		}
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		spoil    bool   // add a note that can't be saved, so the re-key fails
+		wantErr  string // "" for success
+	}{
+		{"changed", "secret", "new", false, ""},
+		{"wrong old password", "wrong", "new", false, ErrBadPassword.Error()},
+		{"empty new password", "secret", "", false, "new password cannot be empty"},
+		{"save fails and rolls back", "secret", "new", true, "year outside of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, notebook := saveTestNotebook(t, "secret")
+			salt, key := notebook.salt, notebook.key
+			if tt.spoil {
+				notebook.Notes = append(notebook.Notes, Note{ID: "bad", Created: time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)})
+			}
+
+			_, _, err := notebook.ChangePassword(tt.old, tt.new)
+			if (err == nil) != (tt.wantErr == "") || err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ChangePassword = %v, want %q", err, tt.wantErr)
+			}
+
+			opens, fails := "secret", tt.new
+			if tt.wantErr == "" {
+				opens, fails = tt.new, "secret"
+				if bytes.Equal(notebook.salt, salt) {
+					t.Error("the salt was not renewed")
+				}
+			} else if notebook.password != "secret" || !bytes.Equal(notebook.salt, salt) || !bytes.Equal(notebook.key, key) {
+				t.Error("the old password, salt and key were not restored")
+			}
+			if _, err := LoadNotebook(path, opens); err != nil {
+				t.Errorf("the file doesn't open with %q: %v", opens, err)
+			}
+			if fails != "" {
+				if _, err := LoadNotebook(path, fails); !errors.Is(err, ErrBadPassword) {
+					t.Errorf("the file opens with %q: %v", fails, err)
+				}
+			}
+			if _, err := os.Stat(path + ".rekey"); err == nil {
+				t.Error("the safety copy was left behind")
+			}
+			if generations, _ := notebook.backupPaths(); len(generations) != 0 {
+				t.Errorf("the password change kept %d backup generations", len(generations))
+			}
+		})
+	}
+}
+
+func TestEnsureIDs(t *testing.T) {
+	tests := []struct {
+		name      string
+		notes     []string // IDs, "" for none
+		trash     []string
+		wantKept  []string // IDs that must survive
+		wantDirty bool
+	}{
+		{"all set", []string{"a", "b"}, []string{"c"}, []string{"a", "b", "c"}, false},
+		{"missing", []string{"", "b", ""}, nil, []string{"b"}, true},
+		{"duplicate in notes", []string{"a", "a"}, nil, []string{"a"}, true},
+		{"duplicate across the trash", []string{"a"}, []string{"a", ""}, []string{"a"}, true},
+	}
+
+	for _, tt := range tests {
+		notebook := NewNotebook("", "")
+		for _, id := range tt.notes {
+			notebook.Notes = append(notebook.Notes, Note{ID: id})
+		}
+		for _, id := range tt.trash {
+			notebook.Trash = append(notebook.Trash, Note{ID: id})
+		}
+		notebook.ensureIDs()
+
+		seen := make(map[string]bool)
+		for _, note := range append(append([]Note{}, notebook.Notes...), notebook.Trash...) {
+			if note.ID == "" || seen[note.ID] {
+				t.Errorf("%s: ID %q is empty or repeated", tt.name, note.ID)
+			}
+			seen[note.ID] = true
+		}
+		for _, id := range tt.wantKept {
+			if !seen[id] {
+				t.Errorf("%s: ID %q was replaced", tt.name, id)
+			}
+		}
+		if notebook.IsDirty() != tt.wantDirty {
+			t.Errorf("%s: dirty = %v, want %v", tt.name, notebook.IsDirty(), tt.wantDirty)
+		}
+	}
+}
+
+func TestNotesByID(t *testing.T) {
+	notebook := NewNotebook("", "")
+	b := NewNote("B", "second", nil)
+	notebook.AddNote(NewNote("C", "third", nil))
+	notebook.AddNote(b)
+	notebook.AddNote(NewNote("A", "first", nil))
+
+	// Sorting moves notes around but their IDs keep finding them
+	notebook.SortNotes(sortByTitle)
+	if note, ok := notebook.GetNote(b.ID); !ok || note.Title != "B" {
+		t.Fatalf("GetNote(%q) = %q, %v", b.ID, note.Title, ok)
+	}
+	if !notebook.UpdateNote(b.ID, "B2", "changed", nil) || !notebook.TogglePin(b.ID) {
+		t.Fatal("updating by ID failed")
+	}
+	if note, _ := notebook.GetNote(b.ID); note.Title != "B2" || !note.Pinned || notebook.Notes[1].ID != b.ID {
+		t.Errorf("note after update = %+v", note)
+	}
+
+	revision := notebook.Revision()
+	for _, id := range []string{"", "missing", b.ID[:4]} {
+		if _, ok := notebook.GetNote(id); ok {
+			t.Errorf("GetNote(%q) found a note", id)
+		}
+		if notebook.UpdateNote(id, "x", "x", nil) || notebook.TogglePin(id) {
+			t.Errorf("changing unknown note %q succeeded", id)
+		}
+		notebook.DeleteNote(id)
+	}
+	if notebook.Revision() != revision || len(notebook.Notes) != 3 {
+		t.Error("unknown IDs changed the notebook")
+	}
+}
+
+func TestMoveAndCopyNote(t *testing.T) {
+	tests := []struct {
+		name      string
+		move      bool
+		badNote   bool // the note can't be saved, so the other notebook's save fails
+		wantMoved bool // the note ended up in the other notebook
+	}{
+		{"move", true, false, true},
+		{"copy", false, false, true},
+		{"failed move keeps the note", true, true, false},
+		{"failed copy", false, true, false},
+	}
+
+	for _, tt := range tests {
+		src := NewNotebook("", "")
+		note := NewNote("Travelling", "suitcase", nil)
+		if tt.badNote {
+			note.Created = time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		src.AddNote(note)
+		_, dst := saveTestNotebook(t, "secret")
+		dst.Search("milk") // builds the index the rollback has to undo
+
+		var err error
+		if tt.move {
+			err = src.MoveNoteTo(note.ID, dst)
+		} else {
+			err = src.CopyNoteTo(note.ID, dst)
+		}
+		if (err == nil) != tt.wantMoved {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+
+		results, _ := dst.Search("suitcase")
+		wantDst := 2
+		if tt.wantMoved {
+			wantDst = 3
+		}
+		if len(dst.Notes) != wantDst || len(results) != wantDst-2 || dst.IsDirty() {
+			t.Errorf("%s: other notebook has %d notes, search finds %d, dirty %v",
+				tt.name, len(dst.Notes), len(results), dst.IsDirty())
+		}
+		if _, ok := src.GetNote(note.ID); ok == (tt.move && tt.wantMoved) {
+			t.Errorf("%s: note still in the source notebook = %v", tt.name, ok)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// notebookEnv overrides where the notebook is looked for when no --file is
+// given.
+const notebookEnv = "ALPAKA_NOTEBOOK"
+
+// dataDir is where notebooks live by default: $XDG_DATA_HOME/alpaka,
+// ~/.local/share/alpaka, or %AppData%\alpaka on Windows.
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "alpaka"), nil
+	}
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "alpaka"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "alpaka"), nil
+}
+
+// configDir holds the app's own unencrypted state, such as the notebook
+// registry. It never contains notes or passwords.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "alpaka"), nil
+}
+
+// defaultNotebookPath picks the notebook used without --file: the one
+// named by $ALPAKA_NOTEBOOK, otherwise notatki.alpaka in the data
+// directory. A notatki.alpaka in the working directory is still used if
+// there is none in the data directory yet, so older setups keep working.
+func defaultNotebookPath() string {
+	if path := os.Getenv(notebookEnv); path != "" {
+		return absPath(path)
+	}
+
+	dir, err := dataDir()
+	if err != nil {
+		return defaultNotebookFile
+	}
+	path := filepath.Join(dir, defaultNotebookFile)
+
+	if !fileExists(path) && fileExists(defaultNotebookFile) {
+		return absPath(defaultNotebookFile)
+	}
+	return path
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// notebookEntry is a notebook the app knows about. The registry only
+// holds locations and display names, never passwords.
+type notebookEntry struct {
+	Path   string    `json:"path"`
+	Name   string    `json:"name,omitempty"`
+	Opened time.Time `json:"opened,omitempty"` // last unlocked
+}
+
+// DisplayName is the name given to the notebook, or its file name
+func (e notebookEntry) DisplayName() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return strings.TrimSuffix(filepath.Base(e.Path), filepath.Ext(e.Path))
+}
+
+func registryFile() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "notebooks.json"), nil
+}
+
+// loadRegistry returns the known notebooks, most recently opened first. A
+// missing or unreadable registry is treated as empty.
+func loadRegistry() []notebookEntry {
+	file, err := registryFile()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	var entries []notebookEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Opened.After(entries[j].Opened)
+	})
+	return entries
+}
+
+func saveRegistry(entries []notebookEntry) error {
+	file, err := registryFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file, data)
+}
+
+// updateRegistry applies change to the entry for path, adding it first if
+// the notebook isn't registered yet
+func updateRegistry(path string, change func(*notebookEntry)) error {
+	path = absPath(path)
+	entries := loadRegistry()
+	for i := range entries {
+		if entries[i].Path == path {
+			change(&entries[i])
+			return saveRegistry(entries)
+		}
+	}
+
+	entry := notebookEntry{Path: path}
+	change(&entry)
+	return saveRegistry(append(entries, entry))
+}
+
+// rememberNotebook records that path was just opened
+func rememberNotebook(path string) error {
+	return updateRegistry(path, func(entry *notebookEntry) {
+		entry.Opened = time.Now()
+	})
+}
+
+// registerNotebook adds path to the registry under name, or renames it if
+// it is already there. An empty name falls back to the file name.
+func registerNotebook(path, name string) error {
+	return updateRegistry(path, func(entry *notebookEntry) {
+		entry.Name = strings.TrimSpace(name)
+	})
+}
+
+// forgetNotebook removes path from the registry. The file itself is left
+// alone.
+func forgetNotebook(path string) error {
+	var entries []notebookEntry
+	for _, entry := range loadRegistry() {
+		if entry.Path != path {
+			entries = append(entries, entry)
+		}
+	}
+	return saveRegistry(entries)
+}
+
+// notebookChoices lists the current notebook followed by the other
+// registered ones
+func notebookChoices(current string) []notebookEntry {
+	current = absPath(current)
+	choices := []notebookEntry{{Path: current}}
+	for _, entry := range loadRegistry() {
+		if entry.Path == current {
+			choices[0] = entry
+		} else {
+			choices = append(choices, entry)
+		}
+	}
+	return choices
+}
+
+// ensureNotebookDir creates the directory a new notebook is saved into.
+// The data directory usually doesn't exist on first launch.
+func ensureNotebookDir(filename string) error {
+	return os.MkdirAll(filepath.Dir(filename), 0700)
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Search queries. Plain words match words in the title, content or tags,
+// ignoring case and diacritics; the last word of a term also matches
+// longer words, so results show up while typing. Field filters look at one
+// thing only:
+//
+//	release            title, content or a tag has the word "release"
+//	"release notes"    the same for the words in a row
+//	title:"v2 plan"    the title has the words
+//	content:todo       the content has the word
+//	tag:work           has the tag "work"
+//	before:2026-01-01  created before that day
+//	after:2025-12-31   created after that day
+//	words>200          more than 200 words (also <, <=, >=, =)
+//
+// Terms next to each other must all match. AND, OR and NOT (or a leading
+// "-") combine them and parentheses group them; NOT binds tighter than
+// AND, which binds tighter than OR. The operators are only recognised in
+// upper case, so "or" is an ordinary word.
+
+// queryNode is a parsed query, or a part of one
+type queryNode interface {
+	// eval returns the IDs of the matching notes
+	eval(ix *searchIndex) noteSet
+	// terms lists the words the query looks for, for ranking. Negated
+	// parts are left out.
+	terms() []scoreTerm
+}
+
+type andQuery struct{ left, right queryNode }
+
+type orQuery struct{ left, right queryNode }
+
+type notQuery struct{ query queryNode }
+
+// textQuery matches words in one field, or in any field
+type textQuery struct {
+	field  int
+	tokens []string
+}
+
+// tagQuery matches a whole tag
+type tagQuery struct {
+	tag string // folded
+}
+
+type dateQuery struct {
+	before bool
+	day    time.Time
+}
+
+type wordsQuery struct {
+	op    string
+	count int
+}
+
+// matchAll is the query for an empty search
+type matchAll struct{}
+
+func (q andQuery) eval(ix *searchIndex) noteSet {
+	return intersect(q.left.eval(ix), q.right.eval(ix))
+}
+
+func (q orQuery) eval(ix *searchIndex) noteSet {
+	return union(q.left.eval(ix), q.right.eval(ix))
+}
+
+func (q notQuery) eval(ix *searchIndex) noteSet {
+	set := ix.all()
+	for id := range q.query.eval(ix) {
+		delete(set, id)
+	}
+	return set
+}
+
+func (q textQuery) eval(ix *searchIndex) noteSet {
+	return ix.matchWords(q.tokens, q.field)
+}
+
+func (q tagQuery) eval(ix *searchIndex) noteSet {
+	return ix.filter(func(doc *indexedNote) bool {
+		for _, tag := range doc.tags {
+			if tag == q.tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (q dateQuery) eval(ix *searchIndex) noteSet {
+	if q.before {
+		return ix.filter(func(doc *indexedNote) bool { return doc.created.Before(q.day) })
+	}
+	// after: the whole day is excluded
+	next := q.day.AddDate(0, 0, 1)
+	return ix.filter(func(doc *indexedNote) bool { return !doc.created.Before(next) })
+}
+
+func (q wordsQuery) eval(ix *searchIndex) noteSet {
+	return ix.filter(func(doc *indexedNote) bool {
+		switch q.op {
+		case "<":
+			return doc.words < q.count
+		case "<=":
+			return doc.words <= q.count
+		case ">":
+			return doc.words > q.count
+		case ">=":
+			return doc.words >= q.count
+		}
+		return doc.words == q.count
+	})
+}
+
+func (matchAll) eval(ix *searchIndex) noteSet { return ix.all() }
+
+func (q andQuery) terms() []scoreTerm { return append(q.left.terms(), q.right.terms()...) }
+
+func (q orQuery) terms() []scoreTerm { return append(q.left.terms(), q.right.terms()...) }
+
+func (notQuery) terms() []scoreTerm { return nil }
+
+func (q textQuery) terms() []scoreTerm {
+	var terms []scoreTerm
+	for i, token := range q.tokens {
+		terms = append(terms, scoreTerm{token: token, prefix: i == len(q.tokens)-1, field: q.field, next: i > 0})
+	}
+	return terms
+}
+
+func (q tagQuery) terms() []scoreTerm {
+	var terms []scoreTerm
+	for i, token := range tokenize(q.tag) {
+		terms = append(terms, scoreTerm{token: token, field: fieldTags, next: i > 0})
+	}
+	return terms
+}
+
+func (dateQuery) terms() []scoreTerm { return nil }
+
+func (wordsQuery) terms() []scoreTerm { return nil }
+
+func (matchAll) terms() []scoreTerm { return nil }
+
+// queryError is a syntax error at a position in the query
+type queryError struct {
+	Pos    int // byte offset into the query
+	Column int // terminal column of Pos, for pointing at it
+	Msg    string
+}
+
+func (e *queryError) Error() string {
+	return fmt.Sprintf("%s (column %d)", e.Msg, e.Column+1)
+}
+
+// parseQuery parses a search query. Syntax errors are *queryError.
+func parseQuery(src string) (queryNode, error) {
+	p := &queryParser{src: src}
+	p.skipSpace()
+	if p.done() {
+		return matchAll{}, nil
+	}
+
+	query, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		// parseOr only stops early at a closing parenthesis
+		return nil, p.errorf(p.pos, "unexpected \")\"")
+	}
+	return query, nil
+}
+
+type queryParser struct {
+	src string
+	pos int
+}
+
+func (p *queryParser) errorf(pos int, format string, args ...interface{}) error {
+	return &queryError{
+		Pos:    pos,
+		Column: runewidth.StringWidth(p.src[:pos]),
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *queryParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *queryParser) skipSpace() {
+	for !p.done() {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+// keyword consumes an upper case operator standing on its own
+func (p *queryParser) keyword(word string) bool {
+	if !strings.HasPrefix(p.src[p.pos:], word) {
+		return false
+	}
+	end := p.pos + len(word)
+	if end < len(p.src) && !isQueryBreak(p.src[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// isQueryBreak reports whether c ends a bare word
+func isQueryBreak(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '(' || c == ')'
+}
+
+// or = and { "OR" and }
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		pos := p.pos
+		if !p.keyword("OR") {
+			return left, nil
+		}
+		p.skipSpace()
+		if p.done() || p.peek() == ')' {
+			return nil, p.errorf(pos, "OR needs a term on both sides")
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orQuery{left, right}
+	}
+}
+
+// and = unary { ["AND"] unary }
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if p.done() || p.peek() == ')' {
+			return left, nil
+		}
+		start := p.pos
+		if p.keyword("OR") {
+			p.pos = start
+			return left, nil
+		}
+		if p.keyword("AND") {
+			p.skipSpace()
+			if p.done() || p.peek() == ')' || p.keyword("OR") {
+				return nil, p.errorf(start, "AND needs a term on both sides")
+			}
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andQuery{left, right}
+	}
+}
+
+// unary = ("NOT" | "-") unary | primary
+func (p *queryParser) parseUnary() (queryNode, error) {
+	p.skipSpace()
+	start := p.pos
+	negated := p.keyword("NOT")
+	if !negated && p.peek() == '-' && p.pos+1 < len(p.src) && (p.src[p.pos+1] == '(' || !isQueryBreak(p.src[p.pos+1])) {
+		p.pos++
+		negated = true
+	}
+	if !negated {
+		return p.parsePrimary()
+	}
+
+	p.skipSpace()
+	if p.done() || p.peek() == ')' {
+		return nil, p.errorf(start, "nothing to negate")
+	}
+	query, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return notQuery{query}, nil
+}
+
+// primary = "(" or ")" | term
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	start := p.pos
+	switch {
+	case p.peek() == '(':
+		p.pos++
+		p.skipSpace()
+		if p.peek() == ')' {
+			return nil, p.errorf(start, "empty parentheses")
+		}
+		if p.done() {
+			return nil, p.errorf(start, "missing \")\"")
+		}
+		query, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() != ')' {
+			return nil, p.errorf(start, "missing \")\"")
+		}
+		p.pos++
+		return query, nil
+	case p.peek() == ')':
+		return nil, p.errorf(start, "unexpected \")\"")
+	case p.keyword("AND"), p.keyword("OR"):
+		return nil, p.errorf(start, "%s needs a term on both sides", p.src[start:p.pos])
+	}
+	return p.parseTerm()
+}
+
+// parseTerm reads a word, a phrase or a field filter
+func (p *queryParser) parseTerm() (queryNode, error) {
+	start := p.pos
+	if p.peek() == '"' {
+		text, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return textQuery{field: anyField, tokens: tokenize(text)}, nil
+	}
+
+	for !p.done() && !isQueryBreak(p.peek()) && p.peek() != '"' && p.peek() != ':' {
+		p.pos++
+	}
+	word := p.src[start:p.pos]
+
+	if p.peek() == ':' && isFieldName(word) {
+		p.pos++
+		return p.parseField(strings.ToLower(word), start)
+	}
+	if strings.HasPrefix(strings.ToLower(word), "words") && len(word) > len("words") {
+		return p.parseWords(word, start)
+	}
+
+	// Not a filter after all, e.g. "12:30", "TODO:" or "a"b": take the
+	// rest of the word as text
+	for !p.done() && !isQueryBreak(p.peek()) {
+		p.pos++
+	}
+	return textQuery{field: anyField, tokens: tokenize(p.src[start:p.pos])}, nil
+}
+
+// isFieldName reports whether word names a filter. Anything else before
+// a colon, like "Note:" or "https:", is searched as text.
+func isFieldName(word string) bool {
+	switch strings.ToLower(word) {
+	case "title", "content", "tag", "before", "after", "words":
+		return true
+	}
+	return false
+}
+
+// parseField reads the value of field:value
+func (p *queryParser) parseField(field string, start int) (queryNode, error) {
+	valueStart := p.pos
+	var value string
+	if p.peek() == '"' {
+		quoted, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		value = quoted
+	} else {
+		for !p.done() && !isQueryBreak(p.peek()) {
+			p.pos++
+		}
+		value = p.src[valueStart:p.pos]
+	}
+	if value == "" {
+		return nil, p.errorf(start, "%s: needs a value", field)
+	}
+
+	switch field {
+	case "title":
+		return textQuery{field: fieldTitle, tokens: tokenize(value)}, nil
+	case "content":
+		return textQuery{field: fieldContent, tokens: tokenize(value)}, nil
+	case "tag":
+		return tagQuery{tag: foldText(value)}, nil
+	case "before", "after":
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return nil, p.errorf(valueStart, "%s: expects a date like 2026-01-31", field)
+		}
+		return dateQuery{before: field == "before", day: day}, nil
+	default: // words
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return nil, p.errorf(valueStart, "words: expects a number")
+		}
+		return wordsQuery{op: "=", count: count}, nil
+	}
+}
+
+// parseWords reads a word count comparison such as words>200
+func (p *queryParser) parseWords(word string, start int) (queryNode, error) {
+	rest := word[len("words"):]
+	op := ""
+	for _, candidate := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		// Just a word that starts with "words"
+		return textQuery{field: anyField, tokens: tokenize(word)}, nil
+	}
+
+	count, err := strconv.Atoi(rest[len(op):])
+	if err != nil || count < 0 {
+		return nil, p.errorf(start+len("words")+len(op), "words%s expects a number", op)
+	}
+	return wordsQuery{op: op, count: count}, nil
+}
+
+// parseQuoted reads a "quoted phrase". There is no escaping; a phrase
+// can't contain a double quote.
+func (p *queryParser) parseQuoted() (string, error) {
+	start := p.pos
+	end := strings.IndexByte(p.src[start+1:], '"')
+	if end < 0 {
+		return "", p.errorf(start, "missing closing quote")
+	}
+	p.pos = start + 1 + end + 1
+	return p.src[start+1 : start+1+end], nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// showQuery prints a query tree compactly, for comparing parses
+func showQuery(q queryNode) string {
+	switch q := q.(type) {
+	case andQuery:
+		return fmt.Sprintf("and(%s %s)", showQuery(q.left), showQuery(q.right))
+	case orQuery:
+		return fmt.Sprintf("or(%s %s)", showQuery(q.left), showQuery(q.right))
+	case notQuery:
+		return fmt.Sprintf("not(%s)", showQuery(q.query))
+	case textQuery:
+		field := map[int]string{anyField: "", fieldTitle: "title:", fieldContent: "content:"}[q.field]
+		return field + strings.Join(q.tokens, "_")
+	case tagQuery:
+		return "tag:" + q.tag
+	case dateQuery:
+		if q.before {
+			return "before:" + q.day.Format("2006-01-02")
+		}
+		return "after:" + q.day.Format("2006-01-02")
+	case wordsQuery:
+		return fmt.Sprintf("words%s%d", q.op, q.count)
+	case matchAll:
+		return "*"
+	}
+	return fmt.Sprintf("%T", q)
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "*"},
+		{"   ", "*"},
+		{"milk", "milk"},
+		{"Zażółć", "zazolc"},
+		{"a b", "and(a b)"},
+		{"a AND b", "and(a b)"},
+		{"a b OR c", "or(and(a b) c)"},
+		{"a OR b c", "or(a and(b c))"},
+		{"a OR b OR c", "or(or(a b) c)"},
+		{"(a OR b) c", "and(or(a b) c)"},
+		{"a AND b OR NOT c", "or(and(a b) not(c))"},
+		{"((a))", "a"},
+
+		// Negation
+		{"-a", "not(a)"},
+		{"NOT a", "not(a)"},
+		{"NOT NOT a", "not(not(a))"},
+		{"--a", "not(not(a))"},
+		{"a -b", "and(a not(b))"},
+		{"-(a OR b)", "not(or(a b))"},
+		{"-tag:archived", "not(tag:archived)"},
+		{"a-b", "a_b"},
+		{"a - b", "and(and(a ) b)"},
+		{"not a", "and(not a)"},
+		{"NOTE", "note"},
+		{"ORDER ANDROID", "and(order android)"},
+
+		// Phrases and fields
+		{`"release notes"`, "release_notes"},
+		{`title:"v2 plan"`, "title:v2_plan"},
+		{"content:todo", "content:todo"},
+		{"TITLE:x", "title:x"},
+		{"tag:Work", "tag:work"},
+		{"tag:Łódź", "tag:lodz"},
+		{"before:2026-01-01 after:2025-12-31", "and(before:2026-01-01 after:2025-12-31)"},
+		{"12:30", "12_30"},
+		{"foo:bar", "foo_bar"},
+		{"Note: budget", "and(note budget)"},
+		{"TODO: fix", "and(todo fix)"},
+		{"https://example.com/a", "https_example_com_a"},
+
+		// Word counts
+		{"words>200", "words>200"},
+		{"words>=5", "words>=5"},
+		{"words<=5", "words<=5"},
+		{"words<10", "words<10"},
+		{"words=3", "words=3"},
+		{"words:3", "words=3"},
+		{"wordsmith", "wordsmith"},
+		{"-words>100", "not(words>100)"},
+	}
+
+	for _, tt := range tests {
+		query, err := parseQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if got := showQuery(query); got != tt.want {
+			t.Errorf("parseQuery(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+		msg    string
+	}{
+		{"a OR", 2, "OR needs a term on both sides"},
+		{"OR a", 0, "OR needs a term on both sides"},
+		{"a AND", 2, "AND needs a term on both sides"},
+		{"a AND OR b", 2, "AND needs a term on both sides"},
+		{"(a", 0, `missing ")"`},
+		{"a (b OR c", 2, `missing ")"`},
+		{"a )", 2, `unexpected ")"`},
+		{"()", 0, "empty parentheses"},
+		{"NOT", 0, "nothing to negate"},
+		{"a -(", 3, `missing ")"`},
+		{"a NOT )", 2, "nothing to negate"},
+		{`"abc`, 0, "missing closing quote"},
+		{`title:"abc`, 6, "missing closing quote"},
+		{"title:", 0, "title: needs a value"},
+		{"words>x", 6, "words> expects a number"},
+		{"words>=-1", 7, "words>= expects a number"},
+		{"words:many", 6, "words: expects a number"},
+		{"before:yesterday", 7, "before: expects a date"},
+		// Columns count terminal cells, not bytes
+		{"zażółć OR", 7, "OR needs a term on both sides"},
+		{"日本 )", 5, `unexpected ")"`},
+	}
+
+	for _, tt := range tests {
+		_, err := parseQuery(tt.query)
+		var syntaxErr *queryError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("parseQuery(%q) error = %v, want a syntax error", tt.query, err)
+			continue
+		}
+		if syntaxErr.Column != tt.column || !strings.HasPrefix(syntaxErr.Msg, tt.msg) {
+			t.Errorf("parseQuery(%q) = %q at column %d, want %q at column %d",
+				tt.query, syntaxErr.Msg, syntaxErr.Column, tt.msg, tt.column)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
 )
 
 // === SPLASH SCREEN ===
@@ -40,8 +44,12 @@ func (m model) viewSplash() string {
     ╚═══════════════════════════════════════════════╝
     `
 
+	if m.width != 0 && m.width < logoWidth {
+		title = "\n🦙 ALPAKA NOTES v2.0 🦙\n"
+	}
+
 	gradientTitle := renderGradientText(title, colorPalette)
-	loadingBar := renderProgressBar(m.splashTicks, 20, 40)
+	loadingBar := renderProgressBar(m.splashTicks, 20, min(40, m.layoutWidth()-20))
 
 	var b strings.Builder
 	b.WriteString(gradientTitle)
@@ -50,19 +58,19 @@ func (m model) viewSplash() string {
 		Foreground(primary).
 		Bold(true).
 		Align(lipgloss.Center).
-		Width(80).
+		Width(m.layoutWidth()).
 		Render(animation[frame]))
 	b.WriteString("\n\n")
 	b.WriteString(lipgloss.NewStyle().
 		Align(lipgloss.Center).
-		Width(80).
+		Width(m.layoutWidth()).
 		Render(loadingBar))
 	b.WriteString("\n\n")
 	b.WriteString(lipgloss.NewStyle().
 		Foreground(muted).
 		Italic(true).
 		Align(lipgloss.Center).
-		Width(80).
+		Width(m.layoutWidth()).
 		Render("Preparing environment..."))
 
 	return lipgloss.Place(m.width, m.height,
@@ -72,75 +80,182 @@ func (m model) viewSplash() string {
 
 // === LOGIN SCREEN ===
 func (m model) updateLogin(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.pickingNotebook {
+		return m.updateNotebookPicker(msg)
+	}
+
 	switch msg.String() {
+	case "tab":
+		m.notebookEntries = notebookChoices(m.filename)
+		m.pickingNotebook = true
+		m.cursor = 0
+		m.err = nil
+		return m, nil
+
 	case "enter":
+		if wait := time.Until(m.loginLockedUntil); wait > 0 {
+			m.err = fmt.Errorf("too many failed attempts, wait %ds", int(wait.Seconds())+1)
+			return m, nil
+		}
+
 		if len(m.passwordBuf) == 0 {
 			m.err = fmt.Errorf("password cannot be empty")
 			return m, nil
 		}
 
-		notebook, err := LoadNotebook(m.filename, m.passwordBuf)
-		if err != nil {
-			notebook = NewNotebook(m.filename, m.passwordBuf)
+		if m.confirmingPassword {
+			if m.confirmBuf != m.passwordBuf {
+				m.err = fmt.Errorf("passwords do not match, try again")
+				m.confirmBuf = ""
+				return m, nil
+			}
+			m.confirmingPassword = false
+			m.confirmBuf = ""
 			m.success = "New notebook created!"
-		} else {
-			m.success = fmt.Sprintf("Loaded %d notes!", len(notebook.Notes))
+			return m.unlock(NewNotebook(m.filename, m.passwordBuf)), nil
 		}
 
-		m.notebook = notebook
-		m.password = m.passwordBuf
-		m.passwordBuf = ""
-		m.screen = screenMenu
+		notebook, err := LoadNotebook(m.filename, m.passwordBuf)
+		switch {
+		case err == nil:
+			m.success = fmt.Sprintf("Loaded %d notes!", len(notebook.Notes))
+			return m.unlock(notebook), nil
+		case errors.Is(err, ErrNotExist):
+			// Only a missing file may start a fresh notebook
+			m.confirmingPassword = true
+			m.err = nil
+		case errors.Is(err, ErrBadPassword):
+			m.loginAttempts++
+			m.loginLockedUntil = time.Now().Add(loginBackoff(m.loginAttempts))
+			m.passwordBuf = ""
+			m.err = fmt.Errorf("invalid password (attempt %d)", m.loginAttempts)
+		default:
+			m.err = err
+		}
 		return m, nil
 
 	case "ctrl+h":
 		m.showPassword = !m.showPassword
-	case "backspace":
-		if len(m.passwordBuf) > 0 {
-			m.passwordBuf = m.passwordBuf[:len(m.passwordBuf)-1]
-		}
 	default:
-		if len(msg.String()) == 1 {
-			m.passwordBuf += msg.String()
+		if m.confirmingPassword {
+			m.confirmBuf = editLine(m.confirmBuf, msg, 500)
+		} else {
+			m.passwordBuf = editLine(m.passwordBuf, msg, 500)
+		}
+	}
+	return m, nil
+}
+
+// unlock switches the session to an opened notebook
+func (m model) unlock(notebook *Notebook) model {
+	m.notebook = notebook
+	m.password = m.passwordBuf
+	m.passwordBuf = ""
+	m.loginAttempts = 0
+	m.err = nil
+	m.screen = screenMenu
+	m.cursor = 0
+
+	// Losing the recent list entry is not worth failing the login for
+	_ = rememberNotebook(m.filename)
+	return m
+}
+
+func (m model) updateNotebookPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.notebookEntries)-1 {
+			m.cursor++
+		}
+	case "tab":
+		m.pickingNotebook = false
+	case "x", "delete":
+		// The current notebook stays on the list
+		if m.cursor == 0 || m.cursor >= len(m.notebookEntries) {
+			return m, nil
+		}
+		if err := forgetNotebook(m.notebookEntries[m.cursor].Path); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.notebookEntries = notebookChoices(m.filename)
+		if m.cursor >= len(m.notebookEntries) {
+			m.cursor = len(m.notebookEntries) - 1
 		}
+	case "enter":
+		if m.cursor >= len(m.notebookEntries) {
+			return m, nil
+		}
+		m.filename = m.notebookEntries[m.cursor].Path
+		m.pickingNotebook = false
+		m.confirmingPassword = false
+		m.confirmBuf = ""
+		m.passwordBuf = ""
+		m.err = nil
 	}
 	return m, nil
 }
 
+// loginBackoff returns how long to refuse logins after n failed attempts.
+// The first few mistakes are free, then the delay doubles up to a minute.
+func loginBackoff(attempts int) time.Duration {
+	if attempts < 3 {
+		return 0
+	}
+	delay := time.Second << (attempts - 3)
+	if delay > time.Minute || delay <= 0 {
+		delay = time.Minute
+	}
+	return delay
+}
+
 func (m model) viewLogin() string {
 	var b strings.Builder
 
-	b.WriteString(renderHeader("WELCOME TO ALPAKA NOTES", "Your private, encrypted notebook"))
+	b.WriteString(m.renderHeader("WELCOME TO ALPAKA NOTES", "Your private, encrypted notebook"))
 	b.WriteString("\n\n")
 
 	infoCard := glowBoxStyle.
-		Width(70).
+		Width(m.boxWidth()).
 		Render(infoStyle.Render(
 			"ℹ First launch? Set a new password.\nReturning user? Enter your password.",
 		))
 	b.WriteString(infoCard)
 	b.WriteString("\n\n")
 
+	if m.pickingNotebook {
+		b.WriteString(m.viewNotebookPicker())
+		return lipgloss.Place(m.width, m.height,
+			lipgloss.Center, lipgloss.Center,
+			b.String())
+	}
+
+	b.WriteString(labelStyle.Render("📁 Notebook: "))
+	b.WriteString(noteMetaStyle.Render(truncatePath(m.filename, max(10, m.boxWidth()-14))))
+	b.WriteString("\n\n")
+
 	passwordLabel := focusedLabelStyle.Render("🔐 Password:")
 	b.WriteString(passwordLabel)
 	b.WriteString("\n")
 
-	var passwordDisplay string
-	if m.showPassword {
-		passwordDisplay = m.passwordBuf
-	} else {
-		passwordDisplay = strings.Repeat("●", len(m.passwordBuf))
-	}
+	passwordDisplay := m.maskPassword(m.passwordBuf, "Enter password...")
+	if m.confirmingPassword {
+		b.WriteString(boxStyle.Width(m.boxWidth()).Render(passwordDisplay))
+		b.WriteString("\n")
 
-	if len(m.passwordBuf) == 0 {
-		passwordDisplay = lipgloss.NewStyle().
-			Foreground(muted).
-			Render("Enter password...")
+		b.WriteString(focusedLabelStyle.Render("🔁 Confirm password:"))
+		b.WriteString("\n")
+		confirmDisplay := m.maskPassword(m.confirmBuf, "Repeat the new password...")
+		confirmDisplay += getAnimatedCursor(m.animFrame)
+		b.WriteString(focusedBoxStyle.Width(m.boxWidth()).Render(confirmDisplay))
+	} else {
+		passwordDisplay += getAnimatedCursor(m.animFrame)
+		b.WriteString(focusedBoxStyle.Width(m.boxWidth()).Render(passwordDisplay))
 	}
-	passwordDisplay += getAnimatedCursor(m.animFrame)
-
-	passwordBox := focusedBoxStyle.Width(70).Render(passwordDisplay)
-	b.WriteString(passwordBox)
 	b.WriteString("\n")
 
 	toggleHint := lipgloss.NewStyle().
@@ -153,32 +268,104 @@ func (m model) viewLogin() string {
 	b.WriteString(toggleHint)
 	b.WriteString("\n\n")
 
+	if m.confirmingPassword {
+		b.WriteString(warningStyle.Render(fmt.Sprintf("⚠ %s does not exist yet — confirm the password to create it", m.filename)))
+		b.WriteString("\n\n")
+	}
+
 	if m.err != nil {
 		b.WriteString(errorStyle.Render(m.err.Error()))
 		b.WriteString("\n\n")
 	}
 
+	if wait := time.Until(m.loginLockedUntil); wait > 0 {
+		b.WriteString(warningStyle.Render(fmt.Sprintf("⏳ Try again in %ds", int(wait.Seconds())+1)))
+		b.WriteString("\n\n")
+	}
+
 	securityInfo := boxStyle.
-		Width(70).
+		Width(m.boxWidth()).
 		BorderForeground(success).
 		Render(
-			"🔒 Your data is protected with AES encryption\n" +
+			"🔒 Your data is protected with AES-256-GCM encryption\n" +
 				"🔐 Password is never stored\n" +
 				"✅ .alpaka format — for your eyes only",
 		)
 	b.WriteString(securityInfo)
 
-	b.WriteString(renderFooter(renderHelp(
-		"Enter", "Login",
-		"Ctrl+H", "Show/Hide",
-		"Ctrl+C", "Quit",
-	)))
+	if m.confirmingPassword {
+		b.WriteString(m.renderFooter(renderHelp(
+			"Enter", "Create",
+			"Ctrl+H", "Show/Hide",
+			"Tab", "Notebook",
+			"Esc", "Back",
+			"Ctrl+C", "Quit",
+		)))
+	} else {
+		b.WriteString(m.renderFooter(renderHelp(
+			"Enter", "Login",
+			"Ctrl+H", "Show/Hide",
+			"Tab", "Notebook",
+			"Ctrl+C", "Quit",
+		)))
+	}
 
 	return lipgloss.Place(m.width, m.height,
 		lipgloss.Center, lipgloss.Center,
 		b.String())
 }
 
+func (m model) viewNotebookPicker() string {
+	var b strings.Builder
+
+	b.WriteString(focusedLabelStyle.Render("📁 Open notebook:"))
+	b.WriteString("\n\n")
+
+	for i, entry := range m.notebookEntries {
+		label := fmt.Sprintf("%s %s", runewidth.FillRight(truncate(entry.DisplayName(), 24), 24),
+			truncatePath(entry.Path, max(10, m.layoutWidth()-50)))
+		if i == 0 {
+			label += "  (current)"
+		} else if !fileExists(entry.Path) {
+			label += "  (missing)"
+		}
+
+		if i == m.cursor {
+			b.WriteString(selectedMenuStyle.Render("▶ " + label))
+		} else {
+			b.WriteString(menuItemStyle.Render("  " + label))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	if m.err != nil {
+		b.WriteString(errorStyle.Render(m.err.Error()))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(m.renderFooter(renderHelp(
+		"↑/↓", "Navigate",
+		"Enter", "Open",
+		"x", "Forget",
+		"Tab/Esc", "Back",
+	)))
+
+	return b.String()
+}
+
+func (m model) maskPassword(password, placeholder string) string {
+	if len(password) == 0 {
+		return lipgloss.NewStyle().
+			Foreground(muted).
+			Render(placeholder)
+	}
+	if m.showPassword {
+		return password
+	}
+	return strings.Repeat("●", utf8.RuneCountInString(password))
+}
+
 // === MENU SCREEN ===
 func (m model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
@@ -187,38 +374,57 @@ func (m model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.cursor--
 		}
 	case "down", "j":
-		if m.cursor < 6 {
+		if m.cursor < 9 {
 			m.cursor++
 		}
+	case "q":
+		return m.quit()
 	case "enter":
 		m.err = nil
 		m.success = ""
 		switch m.cursor {
 		case 0:
 			m.screen = screenAddNote
+			m.editID = ""
 			m.titleBuf = ""
-			m.contentBuf = ""
+			m.content = m.newContentArea()
 			m.tagsBuf = ""
 			m.cursor = 0
 		case 1:
 			m.screen = screenViewNotes
 			m.selected = 0
 			m.scrollOffset = 0
+			m.highlightTerms = nil
+			m.backToSearch = false
 		case 2:
 			m.screen = screenSearch
 			m.searchQuery = ""
+			m.searchFocus = false
+			m.searchID, m.searchCursor = "", 0
 		case 3:
 			m.screen = screenStats
 		case 4:
 			m.screen = screenSettings
 		case 5:
+			m.screen = screenRestore
+			m.selected = 0
+			m.backups = nil
+			m.backupsLoading = true
+			return m, loadBackups(m.notebook)
+		case 6:
+			m.screen = screenTrash
+			m.selected = 0
+			m.notebook.PurgeExpired(time.Now())
+		case 7:
+			m = m.openNotebooks()
+		case 8:
 			if err := m.notebook.Save(); err != nil {
 				m.err = err
 			} else {
 				m.success = "Saved successfully!"
 			}
-		case 6:
-			return m, tea.Quit
+		case 9:
+			return m.quit()
 		}
 	}
 	return m, nil
@@ -227,11 +433,11 @@ func (m model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m model) viewMenu() string {
 	var b strings.Builder
 
-	b.WriteString(renderHeader("Main Menu", "Cheack out your options below"))
+	b.WriteString(m.renderHeader("Main Menu", "Cheack out your options below"))
 	b.WriteString("\n")
 
 	// Stats dashboard
-	statsRow := lipgloss.JoinHorizontal(lipgloss.Top,
+	statsRow := flowBlocks(m.layoutWidth(),
 		statCardStyle.Render(
 			statNumberStyle.Render(fmt.Sprintf("%d", len(m.notebook.Notes)))+"\n"+
 				statLabelStyle.Render("📝 Notes")),
@@ -244,16 +450,16 @@ func (m model) viewMenu() string {
 	)
 	b.WriteString(lipgloss.NewStyle().
 		Align(lipgloss.Center).
-		Width(80).
+		Width(m.layoutWidth()).
 		Render(statsRow))
 	b.WriteString("\n\n")
 
 	// File info
 	fileInfo := boxStyle.
-		Width(70).
+		Width(m.boxWidth()).
 		BorderForeground(accent).
 		Align(lipgloss.Center).
-		Render(fmt.Sprintf("📁 File: %s │ 🔐 Encrypted", m.filename))
+		Render(fmt.Sprintf("📁 File: %s │ 🔐 Encrypted", truncatePath(m.filename, max(10, m.boxWidth()-28))))
 	b.WriteString(fileInfo)
 	b.WriteString("\n\n")
 
@@ -268,6 +474,9 @@ func (m model) viewMenu() string {
 		{"🔍", "Search", "Find specific notes"},
 		{"📊", "Statistics", "Analyze and visualize data"},
 		{"⚙️ ", "Settings", "Sorting and viewing options"},
+		{"♻️ ", "Backups", "Restore an earlier version"},
+		{"🗑️ ", "Trash", "Restore or purge deleted notes"},
+		{"📚", "Notebooks", "Switch notebooks, move and copy notes"},
 		{"💾", "Save", "Save changes to disk"},
 		{"🚪", "Exit", "Close the program"},
 	}
@@ -276,11 +485,17 @@ func (m model) viewMenu() string {
 		itemText := fmt.Sprintf("%s  %s", item.icon, item.text)
 		itemDesc := lipgloss.NewStyle().Foreground(muted).Render(" - " + item.desc)
 
+		var line string
 		if m.cursor == i {
-			b.WriteString(selectedMenuStyle.Render("▶ "+itemText) + itemDesc)
+			line = selectedMenuStyle.Render("▶ " + itemText)
 		} else {
-			b.WriteString(menuItemStyle.Render("  "+itemText) + itemDesc)
+			line = menuItemStyle.Render("  " + itemText)
+		}
+		// Descriptions are left out on narrow terminals
+		if lipgloss.Width(line+itemDesc) <= m.layoutWidth() {
+			line += itemDesc
 		}
+		b.WriteString(line)
 		b.WriteString("\n")
 	}
 
@@ -296,7 +511,7 @@ func (m model) viewMenu() string {
 		b.WriteString("\n")
 	}
 
-	b.WriteString(renderFooter(renderHelp(
+	b.WriteString(m.renderFooter(renderHelp(
 		"↑/↓", "Navigate",
 		"j/k", "Vim",
 		"Enter", "Select",
@@ -322,7 +537,18 @@ func (m model) updateAddNote(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			tags = strings.Fields(m.tagsBuf)
 		}
 
-		note := NewNote(m.titleBuf, m.contentBuf, tags)
+		if m.editID != "" {
+			if !m.notebook.UpdateNote(m.editID, m.titleBuf, m.content.Value(), tags) {
+				m.err = fmt.Errorf("note no longer exists")
+				return m, nil
+			}
+			m.editID = ""
+			m.screen = m.returnScreen
+			m.success = "Note updated!"
+			return m, nil
+		}
+
+		note := NewNote(m.titleBuf, m.content.Value(), tags)
 		m.notebook.AddNote(note)
 
 		m.screen = screenMenu
@@ -334,60 +560,71 @@ func (m model) updateAddNote(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.cursor = (m.cursor + 1) % 3
 	case "shift+tab":
 		m.cursor = (m.cursor - 1 + 3) % 3
-	case "backspace":
+	case "ctrl+e":
+		m.err = nil
+		return m, openInEditor(m.content.Value(), "")
+	default:
 		switch m.cursor {
 		case 0:
-			if len(m.titleBuf) > 0 {
-				m.titleBuf = m.titleBuf[:len(m.titleBuf)-1]
-			}
+			m.titleBuf = editLine(m.titleBuf, msg, 100)
 		case 1:
-			if len(m.contentBuf) > 0 {
-				m.contentBuf = m.contentBuf[:len(m.contentBuf)-1]
-			}
+			m.content.Update(msg)
 		case 2:
-			if len(m.tagsBuf) > 0 {
-				m.tagsBuf = m.tagsBuf[:len(m.tagsBuf)-1]
-			}
-		}
-	case "enter":
-		if m.cursor == 1 {
-			m.contentBuf += "\n"
+			m.tagsBuf = editLine(m.tagsBuf, msg, 200)
 		}
-	default:
-		if len(msg.String()) == 1 || msg.String() == "space" {
-			char := msg.String()
-			if char == "space" {
-				char = " "
+	}
+	return m, nil
+}
+
+// newContentArea sizes the note content editor to fit its box
+func (m model) newContentArea() textArea {
+	return newTextArea(m.contentAreaWidth(), 8, 10000)
+}
+
+// contentAreaWidth is the content box minus padding and a cell for the
+// cursor
+func (m model) contentAreaWidth() int {
+	return m.boxWidth() - boxStyle.GetHorizontalPadding() - 1
+}
+
+// editLine applies typing and backspace to a single-line field, counting
+// runes so multi-byte characters are never split
+func editLine(value string, msg tea.KeyMsg, limit int) string {
+	switch {
+	case msg.String() == "backspace":
+		_, size := utf8.DecodeLastRuneInString(value)
+		return value[:len(value)-size]
+	case (msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace) && !msg.Alt:
+		for _, r := range msg.Runes {
+			if utf8.RuneCountInString(value) >= limit {
+				break
 			}
-			switch m.cursor {
-			case 0:
-				if len(m.titleBuf) < 100 {
-					m.titleBuf += char
-				}
-			case 1:
-				if len(m.contentBuf) < 10000 {
-					m.contentBuf += char
-				}
-			case 2:
-				if len(m.tagsBuf) < 200 {
-					m.tagsBuf += char
-				}
+			if r != '\n' && r != '\r' {
+				value += string(r)
 			}
 		}
 	}
-	return m, nil
+	return value
 }
 
 func (m model) viewAddNote() string {
 	var b strings.Builder
 
-	b.WriteString(renderHeader("NEW NOTE", "Share your thoughts"))
+	if m.editID != "" {
+		b.WriteString(m.renderHeader("EDIT NOTE", "Refine your thoughts"))
+	} else {
+		b.WriteString(m.renderHeader("NEW NOTE", "Share your thoughts"))
+	}
 	b.WriteString("\n")
 
 	// Character counters
-	titleCounter := fmt.Sprintf("%d/100", len(m.titleBuf))
-	contentCounter := fmt.Sprintf("%d/10000", len(m.contentBuf))
-	tagsCounter := fmt.Sprintf("%d/200", len(m.tagsBuf))
+	titleCounter := fmt.Sprintf("%d/100", utf8.RuneCountInString(m.titleBuf))
+	contentCounter := fmt.Sprintf("%d/10000", m.content.Len())
+	if m.cursor == 1 {
+		line, column := m.content.Position()
+		contentCounter += fmt.Sprintf(" │ Ln %d, Col %d", line, column)
+	}
+	tagsCounter := fmt.Sprintf("%d/200", utf8.RuneCountInString(m.tagsBuf))
 
 	// Title field
 	titleLabel := labelStyle.Render("📌 Title:")
@@ -408,9 +645,9 @@ func (m model) viewAddNote() string {
 
 	var titleBox string
 	if m.cursor == 0 {
-		titleBox = focusedBoxStyle.Width(70).Render(titleContent)
+		titleBox = focusedBoxStyle.Width(m.boxWidth()).Render(titleContent)
 	} else {
-		titleBox = boxStyle.Width(70).Render(titleContent)
+		titleBox = boxStyle.Width(m.boxWidth()).Render(titleContent)
 	}
 	b.WriteString(titleBox)
 	b.WriteString("\n")
@@ -424,19 +661,16 @@ func (m model) viewAddNote() string {
 	b.WriteString(contentLabel)
 	b.WriteString("\n")
 
-	contentContent := m.contentBuf
-	if len(contentContent) == 0 && m.cursor != 1 {
+	contentContent := m.content.View(m.cursor == 1, getAnimatedCursor(m.animFrame))
+	if m.content.Len() == 0 && m.cursor != 1 {
 		contentContent = lipgloss.NewStyle().Foreground(muted).Render("Write your thoughts, ideas, memories...")
 	}
-	if m.cursor == 1 {
-		contentContent += getAnimatedCursor(m.animFrame)
-	}
 
 	var contentBox string
 	if m.cursor == 1 {
-		contentBox = focusedBoxStyle.Width(70).Height(10).Render(contentContent)
+		contentBox = focusedBoxStyle.Width(m.boxWidth()).Height(10).Render(contentContent)
 	} else {
-		contentBox = boxStyle.Width(70).Height(10).Render(contentContent)
+		contentBox = boxStyle.Width(m.boxWidth()).Height(10).Render(contentContent)
 	}
 	b.WriteString(contentBox)
 	b.WriteString("\n")
@@ -460,9 +694,9 @@ func (m model) viewAddNote() string {
 
 	var tagsBox string
 	if m.cursor == 2 {
-		tagsBox = focusedBoxStyle.Width(70).Render(tagsContent)
+		tagsBox = focusedBoxStyle.Width(m.boxWidth()).Render(tagsContent)
 	} else {
-		tagsBox = boxStyle.Width(70).Render(tagsContent)
+		tagsBox = boxStyle.Width(m.boxWidth()).Render(tagsContent)
 	}
 	b.WriteString(tagsBox)
 	b.WriteString("\n")
@@ -472,9 +706,12 @@ func (m model) viewAddNote() string {
 		b.WriteString("\n")
 	}
 
-	b.WriteString(renderFooter(renderHelp(
+	b.WriteString(m.renderFooter(renderHelp(
 		"Tab", "Next",
 		"Enter", "New line",
+		"Shift+←/→", "Select",
+		"Ctrl+Z/Y", "Undo/Redo",
+		"Ctrl+E", "$EDITOR",
 		"Ctrl+S", "Save",
 		"Esc", "Cancel",
 	)))
@@ -484,37 +721,196 @@ func (m model) viewAddNote() string {
 		b.String())
 }
 
+// startEdit opens the note form pre-filled with note. Saving updates it in
+// place and goes back to the screen it was opened from.
+func (m model) startEdit(note Note, from screen) model {
+	m.editID = note.ID
+	m.screen = screenAddNote
+	m.returnScreen = from
+	m.titleBuf = note.Title
+	m.content = m.newContentArea()
+	m.content.SetValue(note.Content)
+	m.tagsBuf = strings.Join(note.Tags, " ")
+	m.cursor = 0
+	m.err = nil
+	m.success = ""
+	return m
+}
+
+// handleEditorFinished takes the text back from the external editor, into
+// the note form or straight into the note it was opened for
+func (m model) handleEditorFinished(msg editorFinishedMsg) (tea.Model, tea.Cmd) {
+	content, err := readEditedFile(msg)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	if msg.noteID == "" {
+		if content != m.content.Value() {
+			m.content.SetValue(content)
+		}
+		m.cursor = 1
+		return m, nil
+	}
+
+	note, ok := m.notebook.GetNote(msg.noteID)
+	if !ok {
+		m.err = fmt.Errorf("note no longer exists")
+		return m, nil
+	}
+	if content == note.Content {
+		m.success = "No changes"
+		return m, nil
+	}
+	m.notebook.UpdateNote(note.ID, note.Title, content, note.Tags)
+	m.success = "Note updated!"
+	return m, nil
+}
+
 // === VIEW NOTES SCREEN ===
 func (m model) updateViewNotes(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.viewMode == 2 {
+		if scrolled, ok := m.scrollDetailed(msg.String()); ok {
+			return scrolled, nil
+		}
+	}
+
 	switch msg.String() {
 	case "up", "k":
 		if m.selected > 0 {
 			m.selected--
 		}
+		m.codeBlock = 0
 	case "down", "j":
 		if m.selected < len(m.notebook.Notes)-1 {
 			m.selected++
 		}
+		m.codeBlock = 0
+	case "pgup":
+		m.selected = max(0, m.selected-m.notesPage())
+	case "pgdown":
+		m.selected = max(0, min(len(m.notebook.Notes)-1, m.selected+m.notesPage()))
+	case "home", "g":
+		m.selected = 0
+	case "end", "G":
+		m.selected = max(0, len(m.notebook.Notes)-1)
+	case "b":
+		// Step through the code blocks of the note in the detailed view
+		if note, ok := m.selectedNote(); ok && m.viewMode == 2 {
+			m.codeBlock = (m.codeBlock + 1) % (len(codeBlocks(note.Content)) + 1)
+		}
+	case "y":
+		if note, ok := m.selectedNote(); ok && m.viewMode == 2 {
+			blocks := codeBlocks(note.Content)
+			switch {
+			case m.codeBlock > 0 && m.codeBlock <= len(blocks):
+				return m, copyToClipboard(strings.Join(blocks[m.codeBlock-1].Lines, "\n"), "Code block")
+			case len(blocks) == 1:
+				return m, copyToClipboard(strings.Join(blocks[0].Lines, "\n"), "Code block")
+			case len(blocks) == 0:
+				m.err = fmt.Errorf("this note has no code blocks")
+			default:
+				m.err = fmt.Errorf("select a code block with b first")
+			}
+		}
 	case "d":
-		if len(m.notebook.Notes) > 0 {
-			m.notebook.DeleteNote(m.selected)
+		if note, ok := m.selectedNote(); ok {
+			m.notebook.DeleteNote(note.ID)
 			if m.selected >= len(m.notebook.Notes) && m.selected > 0 {
 				m.selected--
 			}
-			m.success = "Note deleted"
+			m.undoID = note.ID
+			m.success = "Note moved to trash (u to undo)"
+		}
+	case "u":
+		if m.undoID != "" && m.notebook.RestoreNote(m.undoID) {
+			m = m.selectNote(m.undoID)
+			m.success = "Deletion undone"
+		}
+		m.undoID = ""
+	case "e":
+		if note, ok := m.selectedNote(); ok {
+			return m.startEdit(note, screenViewNotes), nil
+		}
+	case "o":
+		if note, ok := m.selectedNote(); ok {
+			m.err = nil
+			return m, openInEditor(note.Content, note.ID)
+		}
+	case "h":
+		if note, ok := m.selectedNote(); ok {
+			m.screen = screenHistory
+			m.historyID = note.ID
+			m.historyVersion = len(note.Revisions)
+			m.err = nil
+			m.success = ""
+		}
+	case "p":
+		if note, ok := m.selectedNote(); ok {
+			m.notebook.TogglePin(note.ID)
+			m = m.selectNote(note.ID)
+			if note.Pinned {
+				m.success = "Note unpinned"
+			} else {
+				m.success = "Note pinned"
+			}
 		}
+	case "m":
+		m = m.transferNote(true)
+	case "c":
+		m = m.transferNote(false)
 	case "v":
 		m.viewMode = (m.viewMode + 1) % 3
+		m.scrollOffset = 0
 	case "s":
+		note, ok := m.selectedNote()
 		m.sortMode = (m.sortMode + 1) % 3
-		m.notebook.SortNotes(m.sortMode)
+		if ok {
+			m = m.selectNote(note.ID)
+		}
 	}
-	return m, nil
+	return m.scrollNotes(), nil
+}
+
+// selectedNote is the note under the cursor in the browser's current order
+func (m model) selectedNote() (Note, bool) {
+	notes := m.notebook.GetSortedNotes(m.sortMode)
+	if m.selected < 0 || m.selected >= len(notes) {
+		return Note{}, false
+	}
+	return notes[m.selected], true
+}
+
+// selectNote moves the cursor to the note with id, so the selection
+// follows a note when the order changes
+func (m model) selectNote(id string) model {
+	for i, note := range m.notebook.GetSortedNotes(m.sortMode) {
+		if note.ID == id {
+			m.selected = i
+			break
+		}
+	}
+	return m
 }
 
 func (m model) viewViewNotes() string {
 	var b strings.Builder
 
+	header, footer := m.notesChrome()
+	lines, _, _ := m.notesBody()
+
+	b.WriteString(header)
+	b.WriteString(renderViewport(lines, m.scrollOffset, m.viewportHeight(header, footer)))
+	b.WriteString(footer)
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Top,
+		b.String())
+}
+
+// notesChrome renders what the browser shows above and below the notes
+func (m model) notesChrome() (header, footer string) {
 	sortModeText := map[sortMode]string{
 		sortByDate:  "Date",
 		sortByTitle: "Title",
@@ -527,204 +923,1237 @@ func (m model) viewViewNotes() string {
 		2: "Details",
 	}[m.viewMode]
 
-	b.WriteString(renderHeader("VIEW NOTES",
-		fmt.Sprintf("Sortowanie: %s │ Widok: %s", sortModeText, viewModeText)))
-	b.WriteString("\n")
-
-	if len(m.notebook.Notes) == 0 {
-		emptyCard := glowBoxStyle.
-			Width(70).
-			Align(lipgloss.Center).
-			Render("📭 No notes\n\n✨ Add your first note to get started!\n\nPress Esc and select 'New Note'")
-		b.WriteString(emptyCard)
-	} else {
-		// View modes
-		notes := m.notebook.GetSortedNotes(m.sortMode)
-
-		switch m.viewMode {
-		case 0: // List view
-			for i, note := range notes {
-				b.WriteString(m.renderNoteCard(note, i == m.selected, false))
-			}
-		case 1: // Grid view
-			for i := 0; i < len(notes); i += 2 {
-				left := m.renderNoteCard(notes[i], i == m.selected, true)
-				right := ""
-				if i+1 < len(notes) {
-					right = m.renderNoteCard(notes[i+1], i+1 == m.selected, true)
-				}
-				row := lipgloss.JoinHorizontal(lipgloss.Top, left, right)
-				b.WriteString(row)
-				b.WriteString("\n")
-			}
-		case 2: // Detailed view
-			if m.selected < len(notes) {
-				b.WriteString(m.renderNoteDetailed(notes[m.selected]))
-			}
+	subtitle := fmt.Sprintf("Sortowanie: %s │ Widok: %s", sortModeText, viewModeText)
+	if m.viewMode == 2 && len(m.highlightTerms) > 0 {
+		if matches := len(m.matchLines()); m.matchIndex >= 0 && m.matchIndex < matches {
+			subtitle += fmt.Sprintf(" │ 🔎 Match %d/%d", m.matchIndex+1, matches)
+		} else {
+			subtitle += fmt.Sprintf(" │ 🔎 %d matches", matches)
 		}
 	}
+	header = m.renderHeader("VIEW NOTES", subtitle) + "\n"
 
+	var b strings.Builder
 	if m.success != "" {
 		b.WriteString("\n")
 		b.WriteString(successStyle.Render("✓ " + m.success))
 	}
+	if m.err != nil {
+		b.WriteString("\n")
+		b.WriteString(errorStyle.Render("✗ " + m.err.Error()))
+	}
 
-	b.WriteString(renderFooter(renderHelp(
-		"↑/↓", "Navigate",
+	help := []string{"↑/↓", "Navigate"}
+	if m.viewMode == 2 {
+		help = []string{"←/→", "Note", "↑/↓/PgUp/PgDn", "Scroll"}
+	}
+	help = append(help,
+		"e", "Edit",
+		"o", "$EDITOR",
+		"h", "History",
+		"p", "Pin",
 		"d", "Delete",
+		"u", "Undo",
+		"m/c", "Move/Copy",
 		"v", "Change view",
 		"s", "Sort",
-		"Esc", "Back",
-	)))
+	)
+	if m.viewMode == 2 {
+		help = append(help, "b", "Code block", "y", "Copy code")
+		if len(m.highlightTerms) > 0 {
+			help = append(help, "n/N", "Next/prev match")
+		}
+	}
+	b.WriteString(m.renderFooter(renderHelp(append(help, "Esc", "Back")...)))
 
-	return lipgloss.Place(m.width, m.height,
-		lipgloss.Center, lipgloss.Top,
-		b.String())
+	return header, b.String()
 }
 
-func (m model) renderNoteCard(note Note, selected bool, compact bool) string {
-	var tagsStr string
-	if len(note.Tags) > 0 {
-		var tagBoxes []string
-		for i, tag := range note.Tags {
-			style := tagStyles[i%len(tagStyles)]
-			tagBoxes = append(tagBoxes, style.Render(tag))
-		}
-		tagsStr = strings.Join(tagBoxes, "")
+// notesBody renders the browser content as lines and reports which lines
+// the selected note takes up
+func (m model) notesBody() (lines []string, top, bottom int) {
+	if len(m.notebook.Notes) == 0 {
+		emptyCard := glowBoxStyle.
+			Width(m.boxWidth()).
+			Align(lipgloss.Center).
+			Render("📭 No notes\n\n✨ Add your first note to get started!\n\nPress Esc and select 'New Note'")
+		lines = strings.Split(emptyCard, "\n")
+		return lines, 0, len(lines) - 1
 	}
 
-	title := noteTitleStyle.Render(note.Title)
-	meta := noteMetaStyle.Render(fmt.Sprintf("📅 %s", note.Timestamp.Format("2006-01-02 15:04")))
+	notes := m.notebook.GetSortedNotes(m.sortMode)
+	add := func(block string, selected bool) {
+		block = strings.TrimSuffix(block, "\n")
+		if selected {
+			top = len(lines)
+		}
+		lines = append(lines, strings.Split(block, "\n")...)
+		if selected {
+			bottom = len(lines) - 1
+		}
+	}
 
-	var preview string
-	if !compact {
-		preview = noteContentStyle.Render(truncate(note.Content, 120))
-	} else {
-		preview = noteContentStyle.Render(truncate(note.Content, 50))
+	switch m.viewMode {
+	case 0: // List view
+		for i, note := range notes {
+			add(m.renderNoteCard(note, i == m.selected, false, cardExtras{}), i == m.selected)
+		}
+	case 1: // Grid view
+		columns, _ := m.gridLayout()
+		for i := 0; i < len(notes); i += columns {
+			var cards []string
+			for j := i; j < i+columns && j < len(notes); j++ {
+				cards = append(cards, m.renderNoteCard(notes[j], j == m.selected, true, cardExtras{}))
+			}
+			row := lipgloss.JoinHorizontal(lipgloss.Top, cards...)
+			add(row, m.selected >= i && m.selected < i+columns)
+		}
+	case 2: // Detailed view
+		if m.selected < len(notes) {
+			add(m.renderNoteDetailed(notes[m.selected]), true)
+		}
 	}
+	return lines, top, bottom
+}
 
-	content := fmt.Sprintf("%s\n%s\n%s\n%s", title, meta, tagsStr, preview)
+// scrollNotes keeps the selected card on screen. In the detailed view it
+// only keeps the scroll position inside the note.
+func (m model) scrollNotes() model {
+	header, footer := m.notesChrome()
+	lines, top, bottom := m.notesBody()
+	height := m.viewportHeight(header, footer)
 
-	var width int
-	if compact {
-		width = 35
-	} else {
-		width = 75
+	if m.viewMode == 2 {
+		return m.clampScroll(len(lines), height)
 	}
+	return m.scrollTo(top, bottom, len(lines), height)
+}
 
-	if selected {
-		return highlightNoteStyle.Width(width).Render(content) + "\n"
+// notesPage is how many notes a page up/down moves the selection by
+func (m model) notesPage() int {
+	header, footer := m.notesChrome()
+	lines, _, _ := m.notesBody()
+	height := m.viewportHeight(header, footer)
+	if height == 0 || len(m.notebook.Notes) == 0 {
+		return 1
 	}
-	return noteCardStyle.Width(width).Render(content) + "\n"
+	perNote := max(1, len(lines)/len(m.notebook.Notes))
+	return max(1, height/perNote)
 }
 
-func (m model) renderNoteDetailed(note Note) string {
-	var tagsStr string
-	if len(note.Tags) > 0 {
-		var tagBoxes []string
-		for i, tag := range note.Tags {
-			style := tagStyles[i%len(tagStyles)]
-			tagBoxes = append(tagBoxes, style.Render(tag))
+// scrollDetailed handles the keys that scroll a note in the detailed view
+func (m model) scrollDetailed(key string) (model, bool) {
+	header, footer := m.notesChrome()
+	height := m.viewportHeight(header, footer)
+
+	switch key {
+	case "up", "k":
+		m.scrollOffset--
+	case "down", "j":
+		m.scrollOffset++
+	case "pgup":
+		m.scrollOffset -= max(1, height-1)
+	case "pgdown", " ":
+		m.scrollOffset += max(1, height-1)
+	case "home", "g":
+		m.scrollOffset = 0
+	case "end", "G":
+		m.scrollOffset = m.maxScroll
+	case "n":
+		m = m.jumpToMatch(1)
+	case "N":
+		m = m.jumpToMatch(-1)
+	case "left":
+		if m.selected > 0 {
+			m.selected--
+			m.scrollOffset = 0
+			m.codeBlock = 0
+			m.matchIndex = -1
+		}
+	case "right":
+		if m.selected < len(m.notebook.Notes)-1 {
+			m.selected++
+			m.scrollOffset = 0
+			m.codeBlock = 0
+			m.matchIndex = -1
 		}
-		tagsStr = strings.Join(tagBoxes, "")
+	default:
+		return m, false
 	}
+	return m.scrollNotes(), true
+}
 
-	title := lipgloss.NewStyle().
-		Foreground(primary).
-		Bold(true).
-		Underline(true).
-		Render(note.Title)
+// jumpToMatch scrolls the detailed view to the next (step 1) or previous
+// (step -1) line with a highlighted search match, wrapping around
+func (m model) jumpToMatch(step int) model {
+	lines := m.matchLines()
+	if len(lines) == 0 {
+		if len(m.highlightTerms) > 0 {
+			m.err = fmt.Errorf("no matches in this note")
+		}
+		return m
+	}
+	m.err = nil
 
-	meta := noteMetaStyle.Render(
-		fmt.Sprintf("📅 %s │ 📊 %d words │ 📏 %d characters",
-			note.Timestamp.Format("2006-01-02 15:04:05"),
-			len(strings.Fields(note.Content)),
-			len(note.Content)))
+	if m.matchIndex < 0 && step < 0 {
+		m.matchIndex = 0
+	}
+	m.matchIndex = (m.matchIndex + step + len(lines)) % len(lines)
+
+	// Leave a little of what comes before the match in view
+	header, footer := m.notesChrome()
+	m.scrollOffset = lines[m.matchIndex] - m.viewportHeight(header, footer)/3
+	return m
+}
 
-	content := noteContentStyle.Render(note.Content)
+// matchLines lists the lines of the detailed view that have a highlighted
+// match in the title or the content
+func (m model) matchLines() []int {
+	note, ok := m.selectedNote()
+	if !ok || len(m.highlightTerms) == 0 {
+		return nil
+	}
 
-	fullContent := fmt.Sprintf("%s\n\n%s\n%s\n\n%s", title, meta, tagsStr, content)
+	head, content := m.detailedParts(note)
+	card := highlightNoteStyle.Width(m.cardWidth())
+	top := card.GetMarginTop() + card.GetBorderTopSize() + card.GetPaddingTop()
 
-	return highlightNoteStyle.Width(75).Render(fullContent) + "\n"
+	var lines []int
+	if len(findMatches(note.Title, m.highlightTerms, fieldTitle)) > 0 {
+		lines = append(lines, top)
+	}
+	// The content starts after however many lines the head wraps to
+	top += lipgloss.Height(card.Render(head+"x")) - lipgloss.Height(card.Render("x"))
+	// Matched in the whole content so a phrase wrapped onto the next line
+	// is still found; it counts for the line it starts on
+	plain := stripANSI(content)
+	for _, match := range findMatches(plain, m.highlightTerms, fieldContent) {
+		line := top + strings.Count(plain[:match.start], "\n")
+		if len(lines) == 0 || lines[len(lines)-1] != line {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// cardExtras are search details shown on a note card
+type cardExtras struct {
+	meta  string        // added to the date line, e.g. the relevance
+	title []int         // rune positions to highlight in the title
+	tags  map[int][]int // rune positions to highlight, by tag index
+
+	snippets []snippet // shown instead of the start of the note
+}
+
+// renderTags renders a note's tags as colored boxes, with the runes in
+// marks highlighted
+func renderTags(tags []string, marks map[int][]int) string {
+	var tagBoxes []string
+	for i, tag := range tags {
+		style := tagStyles[i%len(tagStyles)]
+		if positions := marks[i]; len(positions) > 0 {
+			// Padding is added by hand so the highlighted part keeps the
+			// tag's background
+			inner := style.Copy().UnsetPadding().UnsetMarginRight()
+			tagBoxes = append(tagBoxes, inner.Render(" ")+
+				highlightRunes(tag, positions, inner, inner.Copy().Bold(true).Underline(true))+
+				inner.Render(" ")+" ")
+			continue
+		}
+		tagBoxes = append(tagBoxes, style.Render(tag))
+	}
+	return strings.Join(tagBoxes, "")
+}
+
+func (m model) renderNoteCard(note Note, selected bool, compact bool, extras cardExtras) string {
+	tagsStr := renderTags(note.Tags, extras.tags)
+
+	title := highlightRunes(note.Title, extras.title, noteTitleStyle, matchTitleStyle)
+	if note.Pinned {
+		title = "📌 " + title
+	}
+	metaText := fmt.Sprintf("📅 %s", note.Created.Format("2006-01-02 15:04"))
+	if extras.meta != "" {
+		metaText += " │ " + extras.meta
+	}
+	meta := noteMetaStyle.Render(metaText)
+
+	width := m.cardWidth()
+	if compact {
+		_, width = m.gridLayout()
+	}
+
+	// About two lines of text, whatever the card width
+	preview := noteContentStyle.Render(markdownExcerpt(note.Content, cardTextWidth(width)*5/3))
+	if len(extras.snippets) > 0 {
+		var lines []string
+		for _, s := range extras.snippets {
+			lines = append(lines, highlightRunes(s.text, s.marks, mdTextStyle, matchStyle))
+		}
+		preview = noteContentStyle.Render(strings.Join(lines, "\n"))
+	}
+
+	content := fmt.Sprintf("%s\n%s\n%s\n%s", title, meta, tagsStr, preview)
+
+	if selected {
+		return highlightNoteStyle.Width(width).Render(content) + "\n"
+	}
+	return noteCardStyle.Width(width).Render(content) + "\n"
+}
+
+func (m model) renderNoteDetailed(note Note) string {
+	head, content := m.detailedParts(note)
+	return highlightNoteStyle.Width(m.cardWidth()).Render(head+content) + "\n"
+}
+
+// detailedParts renders what the detailed view shows above the content,
+// and the content. Words the search that opened the note matched are
+// highlighted.
+func (m model) detailedParts(note Note) (head, content string) {
+	tagMarks := make(map[int][]int)
+	for i, tag := range note.Tags {
+		tagMarks[i] = matchRunes(tag, findMatches(tag, m.highlightTerms, fieldTags))
+	}
+	tagsStr := renderTags(note.Tags, tagMarks)
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(primary).
+		Bold(true).
+		Underline(true)
+	title := highlightRunes(note.Title,
+		matchRunes(note.Title, findMatches(note.Title, m.highlightTerms, fieldTitle)),
+		titleStyle, titleStyle.Copy().Foreground(bg).Background(warning))
+	if note.Pinned {
+		title = "📌 " + title
+	}
+
+	modified := ""
+	if note.Modified.After(note.Created) {
+		modified = fmt.Sprintf(" │ ✏️  %s", note.Modified.Format("2006-01-02 15:04:05"))
+	}
+	if len(note.Revisions) > 0 {
+		modified += fmt.Sprintf(" │ 🕘 %d revisions", len(note.Revisions))
+	}
+
+	meta := noteMetaStyle.Render(
+		fmt.Sprintf("📅 %s%s │ 📊 %d words │ 📏 %d characters",
+			note.Created.Format("2006-01-02 15:04:05"),
+			modified,
+			len(strings.Fields(note.Content)),
+			len(note.Content)))
+
+	head = fmt.Sprintf("%s\n\n%s\n%s\n\n", title, meta, tagsStr)
+	content = renderMarkdown(note.Content, cardTextWidth(m.cardWidth()), m.codeBlock, m.highlightTerms)
+	return head, content
+}
+
+// === HISTORY SCREEN ===
+func (m model) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	note, ok := m.notebook.GetNote(m.historyID)
+	if !ok {
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.historyVersion < len(note.Revisions) {
+			m.historyVersion++
+		}
+	case "down", "j":
+		if m.historyVersion > 0 {
+			m.historyVersion--
+		}
+	case "r":
+		if m.historyVersion >= len(note.Revisions) {
+			m.err = fmt.Errorf("this is already the current version")
+			return m, nil
+		}
+		if err := m.notebook.RestoreRevision(m.historyID, m.historyVersion); err != nil {
+			m.err = err
+			return m, nil
+		}
+		restored, _ := m.notebook.GetNote(m.historyID)
+		m.historyVersion = len(restored.Revisions)
+		m.err = nil
+		m.success = "Revision restored!"
+	}
+	return m, nil
+}
+
+func (m model) viewHistory() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader("HISTORY", "Step through earlier versions of a note"))
+	b.WriteString("\n")
+
+	note, ok := m.notebook.GetNote(m.historyID)
+	if !ok {
+		b.WriteString(errorStyle.Render("✗ note no longer exists"))
+		return b.String()
+	}
+
+	versions, err := note.versions()
+	if err != nil {
+		b.WriteString(errorStyle.Render("✗ " + err.Error()))
+		return b.String()
+	}
+
+	// Version list, newest first
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		label := fmt.Sprintf("v%d  %s  %s", i+1,
+			version.Modified.Format("2006-01-02 15:04:05"),
+			truncate(version.Title, 40))
+		if version.Current {
+			label += "  (current)"
+		}
+
+		if i == m.historyVersion {
+			b.WriteString(selectedMenuStyle.Render("▶ " + label))
+		} else {
+			b.WriteString(menuItemStyle.Render("  " + label))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	// Changes made by the selected version
+	selected := versions[m.historyVersion]
+	var previous noteVersion
+	if m.historyVersion > 0 {
+		previous = versions[m.historyVersion-1]
+	}
+	b.WriteString(highlightNoteStyle.Width(m.cardWidth()).Render(renderVersionDiff(previous, selected)))
+	b.WriteString("\n")
+
+	if m.success != "" {
+		b.WriteString(successStyle.Render("✓ " + m.success))
+		b.WriteString("\n")
+	}
+	if m.err != nil {
+		b.WriteString(errorStyle.Render("✗ " + m.err.Error()))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(m.renderFooter(renderHelp(
+		"↑/↓", "Step through versions",
+		"r", "Restore this version",
+		"Esc", "Back",
+	)))
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Top,
+		b.String())
+}
+
+// renderVersionDiff shows what changed from previous to version
+func renderVersionDiff(previous, version noteVersion) string {
+	added := lipgloss.NewStyle().Foreground(success)
+	removed := lipgloss.NewStyle().Foreground(danger)
+	same := lipgloss.NewStyle().Foreground(textDim)
+
+	var b strings.Builder
+	b.WriteString(noteTitleStyle.Render(version.Title))
+	b.WriteString("\n")
+	b.WriteString(noteMetaStyle.Render("📅 " + version.Modified.Format("2006-01-02 15:04:05")))
+	b.WriteString("\n\n")
+
+	if previous.Title != version.Title && previous.Title != "" {
+		b.WriteString(removed.Render("- title: " + previous.Title))
+		b.WriteString("\n")
+		b.WriteString(added.Render("+ title: " + version.Title))
+		b.WriteString("\n")
+	}
+	if !equalTags(previous.Tags, version.Tags) && len(previous.Tags) > 0 {
+		b.WriteString(removed.Render("- tags: " + strings.Join(previous.Tags, " ")))
+		b.WriteString("\n")
+		b.WriteString(added.Render("+ tags: " + strings.Join(version.Tags, " ")))
+		b.WriteString("\n")
+	}
+
+	for _, line := range diffLines(previous.Content, version.Content) {
+		switch line.Op {
+		case diffInsert:
+			b.WriteString(added.Render("+ " + line.Text))
+		case diffDelete:
+			b.WriteString(removed.Render("- " + line.Text))
+		default:
+			b.WriteString(same.Render("  " + line.Text))
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// === TRASH SCREEN ===
+func (m model) updateTrash(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	trash := m.notebook.Trash
+
+	if m.confirmEmptyTrash {
+		m.confirmEmptyTrash = false
+		if msg.String() == "y" {
+			count := m.notebook.EmptyTrash()
+			m.selected = 0
+			m.success = fmt.Sprintf("Trash emptied (%d notes)", count)
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected--
+		}
+	case "down", "j":
+		if m.selected < len(trash)-1 {
+			m.selected++
+		}
+	case "r", "enter":
+		if m.selected < len(trash) {
+			m.notebook.RestoreNote(trash[m.selected].ID)
+			m.success = "Note restored"
+		}
+	case "x":
+		if m.selected < len(trash) {
+			m.notebook.PurgeNote(trash[m.selected].ID)
+			m.success = "Note deleted permanently"
+		}
+	case "X":
+		if len(trash) > 0 {
+			m.confirmEmptyTrash = true
+			m.success = ""
+		}
+	}
+
+	if m.selected >= len(m.notebook.Trash) && m.selected > 0 {
+		m.selected = len(m.notebook.Trash) - 1
+	}
+	return m, nil
+}
+
+func (m model) viewTrash() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader("TRASH", trashRetentionText(m.notebook.trashRetention)))
+	b.WriteString("\n")
+
+	if len(m.notebook.Trash) == 0 {
+		emptyCard := glowBoxStyle.
+			Width(m.boxWidth()).
+			Align(lipgloss.Center).
+			Render("🗑️  Trash is empty\n\nDeleted notes wait here before they are gone for good")
+		b.WriteString(emptyCard)
+	} else {
+		for i, note := range m.notebook.Trash {
+			title := noteTitleStyle.Render(note.Title)
+			deleted := "🗑️  deleted"
+			if note.Deleted != nil {
+				deleted += " " + note.Deleted.Format("2006-01-02 15:04")
+			}
+			meta := noteMetaStyle.Render(deleted)
+			preview := noteContentStyle.Render(truncate(note.Content, cardTextWidth(m.cardWidth())))
+			content := fmt.Sprintf("%s\n%s\n%s", title, meta, preview)
+
+			if i == m.selected {
+				b.WriteString(highlightNoteStyle.Width(m.cardWidth()).Render(content))
+			} else {
+				b.WriteString(noteCardStyle.Width(m.cardWidth()).Render(content))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if m.confirmEmptyTrash {
+		b.WriteString("\n")
+		b.WriteString(warningStyle.Render(fmt.Sprintf(
+			"⚠ Delete all %d notes in the trash for good? (y/n)", len(m.notebook.Trash))))
+	}
+	if m.success != "" {
+		b.WriteString("\n")
+		b.WriteString(successStyle.Render("✓ " + m.success))
+	}
+
+	b.WriteString(m.renderFooter(renderHelp(
+		"↑/↓", "Navigate",
+		"r", "Restore",
+		"x", "Delete forever",
+		"X", "Empty trash",
+		"Esc", "Back",
+	)))
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Top,
+		b.String())
+}
+
+// === NOTEBOOKS SCREEN ===
+
+// notebookPrompt is the input line open on the notebooks screen
+type notebookPrompt int
+
+const (
+	promptNone notebookPrompt = iota
+	promptAddNotebook
+	promptRenameNotebook
+	promptTargetPassword
+)
+
+func (m model) openNotebooks() model {
+	m.screen = screenNotebooks
+	m.notebookEntries = notebookChoices(m.filename)
+	m.notebookPrompt = promptNone
+	m.promptBuf = ""
+	m.selected = 0
+	return m
+}
+
+func (m model) updateNotebooks(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.notebookPrompt != promptNone {
+		return m.updateNotebookPrompt(msg)
+	}
+
+	entries := m.notebookEntries
+	switch msg.String() {
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected--
+		}
+	case "down", "j":
+		if m.selected < len(entries)-1 {
+			m.selected++
+		}
+	case "enter":
+		if m.selected == 0 || m.selected >= len(entries) {
+			return m, nil
+		}
+		return m.switchNotebook(entries[m.selected].Path)
+	case "o":
+		if m.selected == 0 || m.selected >= len(entries) {
+			m.err = fmt.Errorf("pick another notebook to open next to this one")
+			return m, nil
+		}
+		m.notebookPrompt = promptTargetPassword
+		m.promptBuf = ""
+		m.err = nil
+	case "a":
+		m.notebookPrompt = promptAddNotebook
+		m.promptBuf = ""
+		m.err = nil
+	case "r":
+		if m.selected < len(entries) {
+			m.notebookPrompt = promptRenameNotebook
+			m.promptBuf = entries[m.selected].Name
+			m.err = nil
+		}
+	case "x":
+		if m.selected == 0 || m.selected >= len(entries) {
+			return m, nil
+		}
+		if m.target != nil && m.target.filename == entries[m.selected].Path {
+			m.target = nil
+		}
+		if err := forgetNotebook(entries[m.selected].Path); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.notebookEntries = notebookChoices(m.filename)
+		if m.selected >= len(m.notebookEntries) {
+			m.selected = len(m.notebookEntries) - 1
+		}
+		m.success = "Notebook removed from the list (the file is kept)"
+	}
+	return m, nil
+}
+
+func (m model) updateNotebookPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() != "enter" {
+		limit := 500
+		if m.notebookPrompt == promptRenameNotebook {
+			limit = 40
+		}
+		m.promptBuf = editLine(m.promptBuf, msg, limit)
+		return m, nil
+	}
+
+	entry := m.notebookEntries[m.selected]
+	switch m.notebookPrompt {
+	case promptAddNotebook:
+		path := strings.TrimSpace(m.promptBuf)
+		if path == "" {
+			m.err = fmt.Errorf("enter the path of a notebook file")
+			return m, nil
+		}
+		if err := registerNotebook(path, ""); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.notebookEntries = notebookChoices(m.filename)
+		m.success = fmt.Sprintf("Added %s", absPath(path))
+
+	case promptRenameNotebook:
+		if err := registerNotebook(entry.Path, m.promptBuf); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.notebookEntries = notebookChoices(m.filename)
+		m.success = "Notebook renamed"
+
+	case promptTargetPassword:
+		target, err := LoadNotebook(entry.Path, m.promptBuf)
+		m.promptBuf = ""
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.target = target
+		m.success = fmt.Sprintf("%s is open — use m/c in View Notes to move or copy notes", entry.DisplayName())
+	}
+
+	m.notebookPrompt = promptNone
+	m.promptBuf = ""
+	m.err = nil
+	return m, nil
+}
+
+// switchNotebook saves and locks the open notebook, then asks for the
+// password of the one at path
+func (m model) switchNotebook(path string) (tea.Model, tea.Cmd) {
+	if m.notebook.IsDirty() {
+		if err := m.notebook.Save(); err != nil {
+			m.err = fmt.Errorf("not switching, save failed: %w", err)
+			return m, nil
+		}
+	}
+
+	m.notebook = nil
+	m.password = ""
+	m.target = nil
+	m.undoID = ""
+	m.filename = path
+	m.screen = screenLogin
+	m.passwordBuf = ""
+	m.confirmingPassword = false
+	m.confirmBuf = ""
+	m.cursor = 0
+	m.err = nil
+	m.success = ""
+	return m, nil
+}
+
+// transferNote moves or copies the selected note into the second notebook
+func (m model) transferNote(move bool) model {
+	note, ok := m.selectedNote()
+	if !ok {
+		return m
+	}
+	if m.target == nil {
+		m.err = fmt.Errorf("open a second notebook first (Notebooks → o)")
+		return m
+	}
+
+	name := notebookEntry{Path: m.target.filename}.DisplayName()
+	for _, entry := range m.notebookEntries {
+		if entry.Path == m.target.filename {
+			name = entry.DisplayName()
+		}
+	}
+
+	m.err = nil
+	m.success = ""
+	if move {
+		if err := m.notebook.MoveNoteTo(note.ID, m.target); err != nil {
+			m.err = err
+			return m
+		}
+		if m.selected >= len(m.notebook.Notes) && m.selected > 0 {
+			m.selected--
+		}
+		m.success = fmt.Sprintf("Moved to %s", name)
+	} else {
+		if err := m.notebook.CopyNoteTo(note.ID, m.target); err != nil {
+			m.err = err
+			return m
+		}
+		m.success = fmt.Sprintf("Copied to %s", name)
+	}
+	return m
+}
+
+func (m model) viewNotebooks() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader("NOTEBOOKS", "Switch notebooks, move and copy notes"))
+	b.WriteString("\n")
+
+	for i, entry := range m.notebookEntries {
+		title := noteTitleStyle.Render(entry.DisplayName())
+		var status string
+		switch {
+		case i == 0:
+			status = successStyle.Render("● open")
+		case m.target != nil && m.target.filename == entry.Path:
+			status = infoStyle.Render("⇄ open for moving and copying")
+		case !fileExists(entry.Path):
+			status = noteMetaStyle.Render("not created yet")
+		}
+		meta := noteMetaStyle.Render("📁 " + truncatePath(entry.Path, cardTextWidth(m.cardWidth())-3))
+		content := fmt.Sprintf("%s  %s\n%s", title, status, meta)
+
+		if i == m.selected {
+			b.WriteString(highlightNoteStyle.Width(m.cardWidth()).Render(content))
+		} else {
+			b.WriteString(noteCardStyle.Width(m.cardWidth()).Render(content))
+		}
+		b.WriteString("\n")
+	}
+
+	if m.notebookPrompt != promptNone {
+		label := map[notebookPrompt]string{
+			promptAddNotebook:    "📁 Path of the notebook file:",
+			promptRenameNotebook: "✏️  Name:",
+			promptTargetPassword: "🔐 Password of " + m.notebookEntries[m.selected].DisplayName() + ":",
+		}[m.notebookPrompt]
+		b.WriteString(focusedLabelStyle.Render(label))
+		b.WriteString("\n")
+
+		value := m.promptBuf
+		if m.notebookPrompt == promptTargetPassword {
+			value = m.maskPassword(m.promptBuf, "")
+		}
+		b.WriteString(focusedBoxStyle.Width(m.boxWidth()).Render(value + getAnimatedCursor(m.animFrame)))
+		b.WriteString("\n")
+	}
+
+	if m.success != "" {
+		b.WriteString(successStyle.Render("✓ " + m.success))
+		b.WriteString("\n")
+	}
+	if m.err != nil {
+		b.WriteString(errorStyle.Render("✗ " + m.err.Error()))
+		b.WriteString("\n")
+	}
+
+	if m.notebookPrompt != promptNone {
+		b.WriteString(m.renderFooter(renderHelp(
+			"Enter", "Confirm",
+			"Esc", "Cancel",
+		)))
+	} else {
+		b.WriteString(m.renderFooter(renderHelp(
+			"↑/↓", "Navigate",
+			"Enter", "Switch",
+			"o", "Open for move/copy",
+			"a", "Add",
+			"r", "Rename",
+			"x", "Forget",
+			"Esc", "Back",
+		)))
+	}
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Top,
+		b.String())
 }
 
 // === SEARCH SCREEN ===
 func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
-	case "backspace":
-		if len(m.searchQuery) > 0 {
-			m.searchQuery = m.searchQuery[:len(m.searchQuery)-1]
+	case "ctrl+f":
+		m.fuzzySearch = !m.fuzzySearch
+		m.searchID, m.searchCursor = "", 0
+		return m.scrollSearch(), nil
+	case "tab", "shift+tab":
+		m.searchFocus = !m.searchFocus
+		return m.scrollSearch(), nil
+	}
+	if m.searchFocus {
+		return m.updateSearchResults(msg)
+	}
+
+	switch msg.String() {
+	case "down":
+		m.searchFocus = true
+		return m.scrollSearch(), nil
+	case "enter":
+		hits, _ := m.searchHits()
+		if note, ok := m.searchSelection(hits); ok {
+			return m.openSearchHit(note), nil
 		}
-	default:
-		if len(msg.String()) == 1 || msg.String() == "space" {
-			char := msg.String()
-			if char == "space" {
-				char = " "
+		return m, nil
+	}
+	if query := editLine(m.searchQuery, msg, 500); query != m.searchQuery {
+		m.searchQuery = query
+		m.searchID, m.searchCursor = "", 0
+		m.scrollOffset = 0
+	}
+	return m, nil
+}
+
+// updateSearchResults handles the keys while the result cards have the
+// focus. Actions look the note up by its ID, so they hit the right one
+// however the results have moved since.
+func (m model) updateSearchResults(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	hits, err := m.searchHits()
+	cursor := m.searchCursorIn(hits)
+	note, ok := m.searchSelection(hits)
+
+	switch msg.String() {
+	case "up", "k":
+		if cursor == 0 {
+			m.searchFocus = false
+		}
+		cursor--
+	case "down", "j":
+		cursor++
+	case "pgup":
+		cursor -= m.searchPage(hits)
+	case "pgdown":
+		cursor += m.searchPage(hits)
+	case "home", "g":
+		cursor = 0
+	case "end", "G":
+		cursor = len(hits) - 1
+	case "enter":
+		if ok {
+			return m.openSearchHit(note), nil
+		}
+	case "e":
+		if ok {
+			return m.startEdit(note, screenSearch), nil
+		}
+	case "d":
+		if ok {
+			m.notebook.DeleteNote(note.ID)
+			m.undoID = note.ID
+			m.success = "Note moved to trash (u to undo)"
+			// The next result moves up into its place
+			m.searchID = ""
+			return m.scrollSearch(), nil
+		}
+	case "u":
+		if m.undoID != "" && m.notebook.RestoreNote(m.undoID) {
+			m.searchID = m.undoID
+			m.success = "Deletion undone"
+		}
+		m.undoID = ""
+		return m.scrollSearch(), nil
+	case "p":
+		if ok {
+			m.notebook.TogglePin(note.ID)
+			if note.Pinned {
+				m.success = "Note unpinned"
+			} else {
+				m.success = "Note pinned"
 			}
-			m.searchQuery += char
+			return m.scrollSearch(), nil
 		}
 	}
-	return m, nil
+
+	if len(hits) > 0 {
+		cursor = max(0, min(cursor, len(hits)-1))
+		m.searchCursor, m.searchID = cursor, hits[cursor].note.ID
+	}
+	// Only the cursor moved, the results are the same
+	return m.scrollSearchIn(hits, err), nil
+}
+
+// searchCursorIn finds the selected result among hits: the note with
+// searchID if it is still there, otherwise whatever took its place
+func (m model) searchCursorIn(hits []searchHit) int {
+	for i, hit := range hits {
+		if hit.note.ID == m.searchID {
+			return i
+		}
+	}
+	return max(0, min(m.searchCursor, len(hits)-1))
+}
+
+// searchSelection is the note the result cursor is on among hits, fresh
+// from the notebook
+func (m model) searchSelection(hits []searchHit) (Note, bool) {
+	if len(hits) == 0 {
+		return Note{}, false
+	}
+	return m.notebook.GetNote(hits[m.searchCursorIn(hits)].note.ID)
+}
+
+// searchHit is a search result as the search screen shows it
+type searchHit struct {
+	note  Note
+	extra cardExtras
+}
+
+// searchCacheKey is everything the search results depend on. Every edit
+// bumps the notebook revision, so a changed note runs the search again.
+type searchCacheKey struct {
+	notebook *Notebook
+	revision int
+	query    string
+	fuzzy    bool
+	width    int // snippets are cut to the card width
+}
+
+// searchCache keeps the last search results, so that renders and keys
+// that don't change the search reuse them
+type searchCache struct {
+	key  searchCacheKey
+	hits []searchHit
+	err  error
+}
+
+// searchHits returns the results of the current search, running it only
+// if something it depends on has changed
+func (m model) searchHits() ([]searchHit, error) {
+	key := searchCacheKey{
+		notebook: m.notebook,
+		revision: m.notebook.Revision(),
+		query:    m.searchQuery,
+		fuzzy:    m.fuzzySearch,
+		width:    m.cardWidth(),
+	}
+	if m.searchCache.key == key {
+		return m.searchCache.hits, m.searchCache.err
+	}
+
+	hits, err := m.runSearch()
+	*m.searchCache = searchCache{key: key, hits: hits, err: err}
+	return hits, err
+}
+
+// runSearch runs the current search, as a query or fuzzily
+func (m model) runSearch() ([]searchHit, error) {
+	var hits []searchHit
+	if m.fuzzySearch {
+		for _, result := range m.notebook.FuzzySearch(m.searchQuery) {
+			hits = append(hits, searchHit{result.Note, cardExtras{
+				meta:  fmt.Sprintf("⭐ %.0f", result.Score),
+				title: result.TitleMatches,
+				tags:  result.TagMatches,
+			}})
+		}
+		return hits, nil
+	}
+
+	results, err := m.notebook.Search(m.searchQuery)
+	terms := queryTerms(m.searchQuery)
+	for _, result := range results {
+		extra, matches := m.matchExtras(result.Note, terms)
+		extra.meta = fmt.Sprintf("⭐ %.2f", result.Score)
+		switch {
+		case matches == 1:
+			extra.meta += " │ 🎯 1 match"
+		case matches > 1:
+			extra.meta += fmt.Sprintf(" │ 🎯 %d matches", matches)
+		}
+		hits = append(hits, searchHit{result.Note, extra})
+	}
+	return hits, err
+}
+
+// matchExtras highlights the words terms matched in a note's title and
+// tags, cuts snippets of the content around its matches and counts them
+// all
+func (m model) matchExtras(note Note, terms []scoreTerm) (cardExtras, int) {
+	extra := cardExtras{tags: make(map[int][]int)}
+
+	matches := findMatches(note.Title, terms, fieldTitle)
+	extra.title = matchRunes(note.Title, matches)
+	count := len(matches)
+
+	for i, tag := range note.Tags {
+		matches := findMatches(tag, terms, fieldTags)
+		extra.tags[i] = matchRunes(tag, matches)
+		count += len(matches)
+	}
+
+	content := markdownPlain(note.Content)
+	matches = findMatches(content, terms, fieldContent)
+	extra.snippets = makeSnippets(content, matches, cardTextWidth(m.cardWidth()), maxSnippets)
+	return extra, count + len(matches)
+}
+
+// openSearchHit shows a found note in the detailed view, scrolled to the
+// first word the query matched
+func (m model) openSearchHit(note Note) model {
+	m.screen = screenViewNotes
+	m.viewMode = 2
+	m.codeBlock = 0
+	m.scrollOffset = 0
+	m = m.selectNote(note.ID)
+
+	m.backToSearch = true
+
+	m.highlightTerms = nil
+	if !m.fuzzySearch {
+		m.highlightTerms = queryTerms(m.searchQuery)
+	}
+	m.matchIndex = -1
+	if len(m.matchLines()) > 0 {
+		m = m.jumpToMatch(1)
+	}
+	return m.scrollNotes()
 }
 
 func (m model) viewSearch() string {
 	var b strings.Builder
 
-	b.WriteString(renderHeader("SEARCH", "Find your notes instantly"))
+	hits, err := m.searchHits()
+	header, footer := m.searchChrome(hits, err)
+	lines, _, _ := m.searchBody(hits, err)
+
+	b.WriteString(header)
+	b.WriteString(renderViewport(lines, m.scrollOffset, m.viewportHeight(header, footer)))
+	b.WriteString(footer)
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Top,
+		b.String())
+}
+
+// searchChrome renders the query box above the results and the help below
+// them
+func (m model) searchChrome(hits []searchHit, err error) (header, footer string) {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader("SEARCH", "Find your notes instantly"))
 	b.WriteString("\n")
 
 	// Search box
-	searchLabel := focusedLabelStyle.Render("🔍 Search:")
-	b.WriteString(searchLabel)
+	labelText := "🔍 Search:"
+	if m.fuzzySearch {
+		labelText = "🔍 Fuzzy search (titles and tags):"
+	}
+	if m.searchFocus {
+		b.WriteString(labelStyle.Render(labelText))
+	} else {
+		b.WriteString(focusedLabelStyle.Render(labelText))
+	}
 	b.WriteString("\n")
 
 	searchContent := m.searchQuery
+	var syntaxErr *queryError
+	if errors.As(err, &syntaxErr) {
+		searchContent = markQueryError(m.searchQuery, syntaxErr)
+	}
 	if len(searchContent) == 0 {
 		searchContent = lipgloss.NewStyle().Foreground(muted).Render("Type a search term...")
 	}
-	searchContent += getAnimatedCursor(m.animFrame)
-
-	searchBox := focusedBoxStyle.Width(70).Render(searchContent)
-	b.WriteString(searchBox)
+	if m.searchFocus {
+		b.WriteString(boxStyle.Width(m.boxWidth()).Render(searchContent))
+	} else {
+		searchContent += getAnimatedCursor(m.animFrame)
+		b.WriteString(focusedBoxStyle.Width(m.boxWidth()).Render(searchContent))
+	}
 	b.WriteString("\n\n")
 
 	// Search results
-	if len(m.searchQuery) > 0 {
-		results := m.notebook.Search(m.searchQuery)
-
+	if err != nil {
+		b.WriteString(errorStyle.Render("✗ " + err.Error()))
+		b.WriteString("\n")
+	} else if strings.TrimSpace(m.searchQuery) != "" {
 		resultHeader := lipgloss.NewStyle().
 			Foreground(accent).
 			Bold(true).
-			Render(fmt.Sprintf("🎯 Found: %d notes", len(results)))
+			Render(fmt.Sprintf("🎯 Found: %d notes", len(hits)))
 		b.WriteString(resultHeader)
 		b.WriteString("\n\n")
+	}
+	header = b.String()
 
-		if len(results) == 0 {
-			noResults := boxStyle.
-				Width(70).
-				Align(lipgloss.Center).
-				BorderForeground(warning).
-				Render("😕 No matching notes found\n\nTry a different search query")
-			b.WriteString(noResults)
-		} else {
-			for _, note := range results {
-				b.WriteString(m.renderNoteCard(note, false, false))
-			}
-		}
-	} else {
-		helpText := infoStyle.Render("💡 Type anything to start searching\n\nSearch includes titles, content and tags")
-		helpBox := boxStyle.Width(70).Render(helpText)
-		b.WriteString(helpBox)
+	b.Reset()
+	if m.success != "" {
+		b.WriteString("\n")
+		b.WriteString(successStyle.Render("✓ " + m.success))
 	}
 
-	b.WriteString(renderFooter(renderHelp(
-		"Type", "Search",
+	help := []string{"Type", "Search", "↓/Tab", "Results", "Enter", "Open best match"}
+	if m.searchFocus {
+		help = []string{
+			"↑/↓", "Select",
+			"Enter", "Open",
+			"e", "Edit",
+			"p", "Pin",
+			"d", "Delete",
+			"u", "Undo",
+			"Tab", "Query",
+		}
+	}
+	help = append(help,
+		"Ctrl+F", map[bool]string{true: "Query search", false: "Fuzzy search"}[m.fuzzySearch],
 		"Esc", "Back",
-	)))
+	)
+	b.WriteString(m.renderFooter(renderHelp(help...)))
 
-	return lipgloss.Place(m.width, m.height,
-		lipgloss.Center, lipgloss.Top,
-		b.String())
+	return header, b.String()
+}
+
+// searchBody renders the result cards as lines and reports which lines the
+// selected one takes up
+func (m model) searchBody(hits []searchHit, err error) (lines []string, top, bottom int) {
+	switch {
+	case err != nil:
+		return nil, 0, 0
+
+	case strings.TrimSpace(m.searchQuery) == "":
+		helpText := infoStyle.Render("💡 Type anything to start searching\n\n" +
+			"Words match titles, content and tags. Narrow it down with\n" +
+			"title: content: tag: before:2026-01-01 after:2025-12-31 words>200,\n" +
+			"\"a phrase\", AND, OR, NOT or -, and (parentheses).")
+		if m.fuzzySearch {
+			helpText = infoStyle.Render("💡 Type anything to start searching\n\n" +
+				"Fuzzy search matches titles and tags even with typos,\n" +
+				"like \"relnts\" or \"relaese\" for \"Release notes\".")
+		}
+		lines = strings.Split(boxStyle.Width(m.boxWidth()).Render(helpText), "\n")
+		return lines, 0, 0
+
+	case len(hits) == 0:
+		noResults := boxStyle.
+			Width(m.boxWidth()).
+			Align(lipgloss.Center).
+			BorderForeground(warning).
+			Render("😕 No matching notes found\n\nTry a different search query")
+		return strings.Split(noResults, "\n"), 0, 0
+	}
+
+	cursor := m.searchCursorIn(hits)
+	for i, hit := range hits {
+		selected := m.searchFocus && i == cursor
+		card := strings.TrimSuffix(m.renderNoteCard(hit.note, selected, false, hit.extra), "\n")
+		if i == cursor {
+			top = len(lines)
+		}
+		lines = append(lines, strings.Split(card, "\n")...)
+		if i == cursor {
+			bottom = len(lines) - 1
+		}
+	}
+	return lines, top, bottom
+}
+
+// scrollSearch keeps the selected result on screen
+func (m model) scrollSearch() model {
+	hits, err := m.searchHits()
+	return m.scrollSearchIn(hits, err)
+}
+
+// scrollSearchIn is scrollSearch for results that were already computed
+func (m model) scrollSearchIn(hits []searchHit, err error) model {
+	header, footer := m.searchChrome(hits, err)
+	lines, top, bottom := m.searchBody(hits, err)
+	return m.scrollTo(top, bottom, len(lines), m.viewportHeight(header, footer))
+}
+
+// searchPage is how many results a page up/down moves the cursor by
+func (m model) searchPage(hits []searchHit) int {
+	if len(hits) == 0 {
+		return 1
+	}
+	header, footer := m.searchChrome(hits, nil)
+	lines, _, _ := m.searchBody(hits, nil)
+	perHit := max(1, len(lines)/len(hits))
+	return max(1, m.viewportHeight(header, footer)/perHit)
 }
 
 // === STATS SCREEN ===
@@ -735,7 +2164,7 @@ func (m model) updateStats(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m model) viewStats() string {
 	var b strings.Builder
 
-	b.WriteString(renderHeader("STATISTICS", "Analyze your notebook"))
+	b.WriteString(m.renderHeader("STATISTICS", "Analyze your notebook"))
 	b.WriteString("\n\n")
 
 	// Main stats
@@ -747,7 +2176,7 @@ func (m model) viewStats() string {
 		avgWordsPerNote = totalWords / totalNotes
 	}
 
-	statsGrid := lipgloss.JoinHorizontal(lipgloss.Top,
+	statsGrid := flowBlocks(m.layoutWidth(),
 		statCardStyle.
 			BorderForeground(primary).
 			Render(statNumberStyle.Render(fmt.Sprintf("%d", totalNotes))+"\n"+
@@ -766,7 +2195,7 @@ func (m model) viewStats() string {
 				statLabelStyle.Render("📈 Avg. words")),
 	)
 
-	b.WriteString(lipgloss.NewStyle().Align(lipgloss.Center).Width(80).Render(statsGrid))
+	b.WriteString(lipgloss.NewStyle().Align(lipgloss.Center).Width(m.layoutWidth()).Render(statsGrid))
 	b.WriteString("\n\n")
 
 	// Tag cloud
@@ -792,7 +2221,7 @@ func (m model) viewStats() string {
 		})
 
 		tagDisplay := strings.Join(tagList, " ")
-		tagBox := boxStyle.Width(75).Render(tagDisplay)
+		tagBox := boxStyle.Width(m.cardWidth()).Render(tagDisplay)
 		b.WriteString(tagBox)
 		b.WriteString("\n\n")
 	}
@@ -811,14 +2240,14 @@ func (m model) viewStats() string {
 			recentItem := lipgloss.NewStyle().
 				Foreground(textDim).
 				Render(fmt.Sprintf("• %s - %s",
-					note.Timestamp.Format("2006-01-02"),
-					truncate(note.Title, 40)))
+					note.Created.Format("2006-01-02"),
+					truncate(note.Title, min(40, m.layoutWidth()-20))))
 			b.WriteString(recentItem)
 			b.WriteString("\n")
 		}
 	}
 
-	b.WriteString(renderFooter(renderHelp(
+	b.WriteString(m.renderFooter(renderHelp(
 		"Esc", "Back to menu",
 	)))
 
@@ -835,7 +2264,7 @@ func (m model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.cursor--
 		}
 	case "down", "j":
-		if m.cursor < 2 {
+		if m.cursor < 5 {
 			m.cursor++
 		}
 	case "enter", "space":
@@ -844,15 +2273,37 @@ func (m model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.sortMode = (m.sortMode + 1) % 3
 		case 1:
 			m.viewMode = (m.viewMode + 1) % 3
+		case 2:
+			m.notebook.SetRetention(nextRetentionPreset(m.notebook.retention))
+		case 3:
+			m.notebook.SetAutosaveInterval(nextAutosaveInterval(m.notebook.autosaveInterval))
+		case 4:
+			m.notebook.SetTrashRetention(nextTrashRetention(m.notebook.trashRetention))
+		case 5:
+			m.screen = screenChangePassword
+			m.oldPasswordBuf = ""
+			m.newPasswordBuf = ""
+			m.confirmBuf = ""
+			m.cursor = 0
+			m.err = nil
 		}
 	}
 	return m, nil
 }
 
+func nextRetentionPreset(current retentionPolicy) retentionPolicy {
+	for i, preset := range retentionPresets {
+		if preset == current {
+			return retentionPresets[(i+1)%len(retentionPresets)]
+		}
+	}
+	return retentionPresets[0]
+}
+
 func (m model) viewSettings() string {
 	var b strings.Builder
 
-	b.WriteString(renderHeader("SETTINGS", "Customize appearance and behavior"))
+	b.WriteString(m.renderHeader("SETTINGS", "Customize appearance and behavior"))
 	b.WriteString("\n\n")
 
 	// Settings options
@@ -875,6 +2326,10 @@ func (m model) viewSettings() string {
 	}{
 		{"📊", "Sorting", sortModeText},
 		{"👁️ ", "Note view", viewModeText},
+		{"♻️ ", "Backups kept", m.notebook.retention.String()},
+		{"⏱️ ", "Autosave", autosaveText(m.notebook.autosaveInterval)},
+		{"🗑️ ", "Trash", trashRetentionText(m.notebook.trashRetention)},
+		{"🔑", "Password", "Change the notebook password"},
 		{"💾", "File format", ".alpaka (encrypted)"},
 	}
 
@@ -885,10 +2340,10 @@ func (m model) viewSettings() string {
 			lipgloss.NewStyle().Foreground(primary).Bold(true).Render(setting.name),
 			lipgloss.NewStyle().Foreground(textDim).Render("► "+setting.value))
 
-		if m.cursor == i && i < 2 {
-			settingBox = selectedNoteStyle.Width(70).Render(content)
+		if m.cursor == i && i < 6 {
+			settingBox = selectedNoteStyle.Width(m.boxWidth()).Render(content)
 		} else {
-			settingBox = noteCardStyle.Width(70).Render(content)
+			settingBox = noteCardStyle.Width(m.boxWidth()).Render(content)
 		}
 
 		b.WriteString(settingBox)
@@ -897,9 +2352,9 @@ func (m model) viewSettings() string {
 
 	b.WriteString("\n")
 	hint := infoStyle.Render("💡 Select an option to change the setting")
-	b.WriteString(boxStyle.Width(70).Render(hint))
+	b.WriteString(boxStyle.Width(m.boxWidth()).Render(hint))
 
-	b.WriteString(renderFooter(renderHelp(
+	b.WriteString(m.renderFooter(renderHelp(
 		"↑/↓", "Navigate",
 		"Enter/Space", "Change",
 		"Esc", "Back",
@@ -910,10 +2365,258 @@ func (m model) viewSettings() string {
 		b.String())
 }
 
+// === CHANGE PASSWORD SCREEN ===
+func (m model) updateChangePassword(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	fields := []*string{&m.oldPasswordBuf, &m.newPasswordBuf, &m.confirmBuf}
+
+	switch msg.String() {
+	case "enter":
+		if m.cursor < 2 {
+			m.cursor++
+			return m, nil
+		}
+		if m.newPasswordBuf != m.confirmBuf {
+			m.err = fmt.Errorf("new passwords do not match")
+			m.confirmBuf = ""
+			return m, nil
+		}
+		rekeyed, removed, err := m.notebook.ChangePassword(m.oldPasswordBuf, m.newPasswordBuf)
+		if err != nil && !errors.Is(err, ErrOldBackups) {
+			if errors.Is(err, ErrBadPassword) {
+				m.cursor = 0
+				m.oldPasswordBuf = ""
+			}
+			m.err = err
+			return m, nil
+		}
+
+		m.password = m.newPasswordBuf
+		m.oldPasswordBuf, m.newPasswordBuf, m.confirmBuf = "", "", ""
+		m.screen = screenMenu
+		m.cursor = 0
+		m.err = err
+		m.success = "Password changed, notebook re-encrypted!"
+		if summary := rekeySummary(rekeyed, removed); summary != "" {
+			m.success = fmt.Sprintf("Password changed, notebook re-encrypted (%s)!", summary)
+		}
+	case "tab", "down":
+		m.cursor = (m.cursor + 1) % 3
+	case "shift+tab", "up":
+		m.cursor = (m.cursor - 1 + 3) % 3
+	case "ctrl+h":
+		m.showPassword = !m.showPassword
+	default:
+		field := fields[m.cursor]
+		*field = editLine(*field, msg, 500)
+	}
+	return m, nil
+}
+
+func (m model) viewChangePassword() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader("CHANGE PASSWORD", "Re-encrypt the notebook with a new password"))
+	b.WriteString("\n")
+
+	fields := []struct {
+		label       string
+		value       string
+		placeholder string
+	}{
+		{"🔐 Current password:", m.oldPasswordBuf, "Enter the current password..."},
+		{"🔑 New password:", m.newPasswordBuf, "Enter a new password..."},
+		{"🔁 Confirm new password:", m.confirmBuf, "Repeat the new password..."},
+	}
+
+	for i, field := range fields {
+		display := m.maskPassword(field.value, field.placeholder)
+		if m.cursor == i {
+			b.WriteString(focusedLabelStyle.Render(field.label))
+			b.WriteString("\n")
+			b.WriteString(focusedBoxStyle.Width(m.boxWidth()).Render(display + getAnimatedCursor(m.animFrame)))
+		} else {
+			b.WriteString(labelStyle.Render(field.label))
+			b.WriteString("\n")
+			b.WriteString(boxStyle.Width(m.boxWidth()).Render(display))
+		}
+		b.WriteString("\n")
+	}
+
+	if m.err != nil {
+		b.WriteString(errorStyle.Render("✗ " + m.err.Error()))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(m.renderFooter(renderHelp(
+		"Tab", "Next",
+		"Enter", "Confirm",
+		"Ctrl+H", "Show/Hide",
+		"Esc", "Cancel",
+	)))
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Top,
+		b.String())
+}
+
+// === RESTORE SCREEN ===
+type backupsLoadedMsg struct {
+	backups []backupGeneration
+	err     error
+}
+
+// loadBackups decrypts the backup generations in the background, each one
+// may need its own Argon2id run.
+func loadBackups(notebook *Notebook) tea.Cmd {
+	return func() tea.Msg {
+		backups, err := notebook.ListBackups()
+		return backupsLoadedMsg{backups: backups, err: err}
+	}
+}
+
+func (m model) updateRestore(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected--
+		}
+	case "down", "j":
+		if m.selected < len(m.backups)-1 {
+			m.selected++
+		}
+	case "enter":
+		if m.backupsLoading || m.selected >= len(m.backups) {
+			return m, nil
+		}
+		backup := m.backups[m.selected]
+		if backup.Err != nil {
+			m.err = fmt.Errorf("can't restore: %v", backup.Err)
+			return m, nil
+		}
+		if err := m.notebook.RestoreBackup(backup.Path); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.screen = screenMenu
+		m.cursor = 0
+		m.err = nil
+		m.success = fmt.Sprintf("Restored %d notes from %s",
+			backup.Notes, backup.Time.Format("2006-01-02 15:04"))
+	}
+	return m, nil
+}
+
+func (m model) viewRestore() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader("BACKUPS", "Restore an earlier version of your notebook"))
+	b.WriteString("\n")
+
+	switch {
+	case m.backupsLoading:
+		b.WriteString(infoStyle.Render("🔓 Decrypting backups..."))
+		b.WriteString("\n")
+	case len(m.backups) == 0:
+		emptyCard := glowBoxStyle.
+			Width(m.boxWidth()).
+			Align(lipgloss.Center).
+			Render("📭 No backups yet\n\nA backup is made every time the notebook is saved")
+		b.WriteString(emptyCard)
+	default:
+		for i, backup := range m.backups {
+			date := noteTitleStyle.Render(backup.Time.Format("2006-01-02 15:04:05"))
+
+			var info string
+			if backup.Err != nil {
+				info = errorStyle.Render("🔒 " + backup.Err.Error())
+			} else {
+				info = noteMetaStyle.Render(fmt.Sprintf("📝 %d notes", backup.Notes))
+			}
+
+			content := fmt.Sprintf("%s\n%s", date, info)
+			if i == m.selected {
+				b.WriteString(highlightNoteStyle.Width(m.boxWidth()).Render(content))
+			} else {
+				b.WriteString(noteCardStyle.Width(m.boxWidth()).Render(content))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n")
+	b.WriteString(noteMetaStyle.Render("Keeping: " + m.notebook.retention.String()))
+	b.WriteString("\n")
+
+	if m.err != nil {
+		b.WriteString(errorStyle.Render("✗ " + m.err.Error()))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(m.renderFooter(renderHelp(
+		"↑/↓", "Navigate",
+		"Enter", "Restore",
+		"Esc", "Back",
+	)))
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Top,
+		b.String())
+}
+
 // === HELPERS ===
-func truncate(s string, max int) string {
-	if len(s) <= max {
-		return s
+// highlightRunes renders text in base with the runes at positions in mark.
+// Each run is rendered on its own so the styles don't reset each other.
+func highlightRunes(text string, positions []int, base, mark lipgloss.Style) string {
+	if len(positions) == 0 {
+		return base.Render(text)
 	}
-	return s[:max] + "..."
+	marked := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		marked[p] = true
+	}
+
+	var b strings.Builder
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		end := start
+		for end < len(runes) && marked[end] == marked[start] {
+			end++
+		}
+		style := base
+		if marked[start] {
+			style = mark
+		}
+		b.WriteString(style.Render(string(runes[start:end])))
+		start = end
+	}
+	return b.String()
+}
+
+// markQueryError highlights the character a query syntax error points at
+func markQueryError(query string, err *queryError) string {
+	mark := lipgloss.NewStyle().Foreground(bg).Background(danger)
+	if err.Pos >= len(query) {
+		return query + mark.Render(" ")
+	}
+	_, size := utf8.DecodeRuneInString(query[err.Pos:])
+	return query[:err.Pos] + mark.Render(query[err.Pos:err.Pos+size]) + query[err.Pos+size:]
+}
+
+// truncatePath shortens a path from the left, where the least useful part
+// of it is
+func truncatePath(path string, max int) string {
+	runes := []rune(path)
+	if len(runes) <= max {
+		return path
+	}
+	if max <= 3 {
+		return "..."
+	}
+	return "..." + string(runes[len(runes)-max+3:])
+}
+
+// truncate shortens s to at most max terminal cells, ending in "..." when
+// anything was cut
+func truncate(s string, max int) string {
+	return runewidth.Truncate(s, max, "...")
 }
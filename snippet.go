@@ -0,0 +1,175 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Finding the words a search matched, for highlighting them and for
+// showing the parts of a note around them.
+
+// textMatch is a matched word or phrase, as byte offsets into the text
+type textMatch struct {
+	start, end int
+}
+
+// findMatches returns the words of text that terms look for in field.
+// Words are compared folded, the way the search index stores them. Terms
+// that continue a phrase only match together with the rest of it, so a
+// phrase is one match spanning all its words.
+func findMatches(text string, terms []scoreTerm, field int) []textMatch {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// The words of the text, folded
+	var words []textMatch
+	var folded []string
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			words = append(words, textMatch{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, textMatch{start, len(text)})
+	}
+	for _, word := range words {
+		folded = append(folded, foldText(text[word.start:word.end]))
+	}
+
+	var matches []textMatch
+	for _, phrase := range phrases(terms) {
+		if phrase[0].field != anyField && phrase[0].field != field {
+			continue
+		}
+	next:
+		for i := 0; i+len(phrase) <= len(words); i++ {
+			for k, term := range phrase {
+				word := folded[i+k]
+				if word != term.token && !(term.prefix && strings.HasPrefix(word, term.token)) {
+					continue next
+				}
+			}
+			matches = append(matches, textMatch{words[i].start, words[i+len(phrase)-1].end})
+		}
+	}
+
+	// In text order, without overlaps when several terms hit the same words
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	var kept []textMatch
+	for _, match := range matches {
+		if len(kept) == 0 || match.start >= kept[len(kept)-1].end {
+			kept = append(kept, match)
+		}
+	}
+	return kept
+}
+
+// phrases groups terms into the phrases they came from
+func phrases(terms []scoreTerm) [][]scoreTerm {
+	var groups [][]scoreTerm
+	for _, term := range terms {
+		if term.next && len(groups) > 0 {
+			groups[len(groups)-1] = append(groups[len(groups)-1], term)
+		} else {
+			groups = append(groups, []scoreTerm{term})
+		}
+	}
+	return groups
+}
+
+// matchRunes turns matches into the rune positions they cover
+func matchRunes(text string, matches []textMatch) []int {
+	var positions []int
+	for _, match := range matches {
+		first := utf8.RuneCountInString(text[:match.start])
+		count := utf8.RuneCountInString(text[match.start:match.end])
+		for i := 0; i < count; i++ {
+			positions = append(positions, first+i)
+		}
+	}
+	return positions
+}
+
+// maxSnippets is how many snippets a search result card shows
+const maxSnippets = 3
+
+// snippet is a piece of a note around one or more matches. marks are the
+// matched runes within text.
+type snippet struct {
+	text  string
+	marks []int
+}
+
+// makeSnippets cuts up to count snippets of width runes out of text, each
+// starting a little before a match that the previous one didn't show
+func makeSnippets(text string, matches []textMatch, width, count int) []snippet {
+	runes := []rune(text)
+	width = max(width-2, 10) // room for the ellipses
+
+	var snippets []snippet
+	shown := 0 // runes before this are in an earlier snippet
+	for _, match := range matches {
+		if len(snippets) == count {
+			break
+		}
+		first := utf8.RuneCountInString(text[:match.start])
+		if first < shown {
+			continue
+		}
+
+		// A third of the room goes before the match, starting at a word
+		start := max(first-width/3, shown)
+		if start > 0 {
+			if space := strings.IndexRune(string(runes[start:first]), ' '); space >= 0 {
+				start += utf8.RuneCountInString(string(runes[start:first])[:space]) + 1
+			}
+		}
+		end := min(start+width, len(runes))
+
+		var s snippet
+		if start > 0 {
+			s.text = "…"
+		}
+		offset := utf8.RuneCountInString(s.text) - start
+		s.text += string(runes[start:end])
+		if end < len(runes) {
+			s.text += "…"
+		}
+		for _, position := range matchRunes(text, matches) {
+			if position >= start && position < end {
+				s.marks = append(s.marks, position+offset)
+			}
+		}
+
+		snippets = append(snippets, s)
+		shown = end
+	}
+	return snippets
+}
+
+var ansiRe = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+// stripANSI removes terminal styling from rendered text
+func stripANSI(s string) string {
+	return ansiRe.ReplaceAllString(s, "")
+}
+
+// queryTerms lists the words a query looks for, or none if it doesn't
+// parse
+func queryTerms(src string) []scoreTerm {
+	query, err := parseQuery(src)
+	if err != nil {
+		return nil
+	}
+	return query.terms()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// showMatches prints the matched parts of text joined by "|"
+func showMatches(text string, matches []textMatch) string {
+	var parts []string
+	for _, match := range matches {
+		parts = append(parts, text[match.start:match.end])
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestFindMatches(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		field int
+		want  string
+	}{
+		{"milk", "Buy milk, MILK and milkshakes", fieldContent, "milk|MILK|milkshakes"},
+		{"zolw", "Żółw i żółwie", fieldContent, "Żółw|żółwie"},
+		{"ilk", "milk", fieldContent, ""},
+		{"milk bread", "bread and milk", fieldContent, "bread|milk"},
+
+		// A phrase matches its words in a row, the last one as a prefix
+		{`"buy milk"`, "milk, buy bread, buy milkshakes", fieldContent, "buy milkshakes"},
+		{`"buy milk"`, "buying milk", fieldContent, ""},
+		{`"buy milk"`, "buy\nmilk", fieldContent, "buy\nmilk"},
+		{`"buy milk" milk`, "buy milk", fieldContent, "buy milk"},
+
+		// Field filters only highlight their own field
+		{"title:milk", "milk", fieldContent, ""},
+		{"title:milk", "milk", fieldTitle, "milk"},
+		{"tag:home", "homework", fieldTags, ""},
+		{"tag:home", "home", fieldTags, "home"},
+
+		// Negated words and unparsable queries highlight nothing
+		{"-milk", "milk", fieldContent, ""},
+		{`"milk`, "milk", fieldContent, ""},
+	}
+	for _, tt := range tests {
+		got := showMatches(tt.text, findMatches(tt.text, queryTerms(tt.query), tt.field))
+		if got != tt.want {
+			t.Errorf("%s in %q = %q, want %q", tt.query, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMatchRunes(t *testing.T) {
+	text := "żółw i kot"
+	matches := findMatches(text, queryTerms("zolw kot"), fieldContent)
+	if got, want := matchRunes(text, matches), []int{0, 1, 2, 3, 7, 8, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("matchRunes = %v, want %v", got, want)
+	}
+}
+
+// showSnippet prints a snippet with its marked runes in brackets
+func showSnippet(s snippet) string {
+	marked := make(map[int]bool)
+	for _, position := range s.marks {
+		marked[position] = true
+	}
+	var b strings.Builder
+	for i, r := range []rune(s.text) {
+		if marked[i] && !marked[i-1] {
+			b.WriteByte('[')
+		}
+		b.WriteRune(r)
+		if marked[i] && !marked[i+1] {
+			b.WriteByte(']')
+		}
+	}
+	return b.String()
+}
+
+func TestMakeSnippets(t *testing.T) {
+	long := "The first part talks about something else entirely. " +
+		"Then the milk shows up in the middle of the note. " +
+		"After a lot more text about other things it ends with milk again."
+
+	tests := []struct {
+		text  string
+		query string
+		width int
+		count int
+		want  []string
+	}{
+		// A third of the room goes before the match, starting at a word
+		{"short note with milk", "milk", 40, 3, []string{"…note with [milk]"}},
+		{long, "milk", 30, 3, []string{
+			"…the [milk] shows up in the mid…",
+			"…with [milk] again.",
+		}},
+		{long, "milk", 30, 1, []string{"…the [milk] shows up in the mid…"}},
+		// The next snippet never starts before the previous one ended
+		{long, "milk", 120, 3, []string{
+			"…something else entirely. Then the [milk] shows up in the middle of the note. After a lot more text about other things it…",
+			"…ends with [milk] again.",
+		}},
+		{"żółw żółw żółw", "zolw", 40, 3, []string{"[żółw] [żółw] [żółw]"}},
+		{long, "nothing", 30, 3, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, s := range makeSnippets(tt.text, findMatches(tt.text, queryTerms(tt.query), fieldContent), tt.width, tt.count) {
+			got = append(got, showSnippet(s))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("snippets of %q for %s = %q, want %q", tt.text[:min(20, len(tt.text))], tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+// textArea is a multi-line, rune-aware text editor used for note content.
+// It keeps a cursor and an optional selection as rune offsets, soft-wraps
+// to its width and has its own undo/redo history.
+type textArea struct {
+	runes  []rune
+	cursor int
+	anchor int // other end of the selection, -1 when nothing is selected
+	goal   int // preferred column for up/down moves, -1 when unset
+
+	width  int // wrap width in terminal cells
+	height int // visible lines
+	offset int // first visible wrapped line
+	limit  int // maximum length in runes, 0 for no limit
+
+	undo     []textSnapshot
+	redo     []textSnapshot
+	lastEdit editKind
+}
+
+type textSnapshot struct {
+	text   string
+	cursor int
+}
+
+// editKind groups consecutive edits of the same kind into one undo step,
+// so undo removes a typed word rather than a single letter
+type editKind int
+
+const (
+	editNone editKind = iota
+	editInsert
+	editDelete
+	editOther
+)
+
+const maxUndo = 200
+
+// visualLine is a wrapped line as a [start, end) range of rune offsets
+type visualLine struct {
+	start, end int
+}
+
+func newTextArea(width, height, limit int) textArea {
+	return textArea{
+		anchor: -1,
+		goal:   -1,
+		width:  width,
+		height: height,
+		limit:  limit,
+	}
+}
+
+func (t *textArea) SetValue(s string) {
+	t.runes = []rune(s)
+	t.cursor = len(t.runes)
+	t.anchor = -1
+	t.goal = -1
+	t.offset = 0
+	t.undo = nil
+	t.redo = nil
+	t.lastEdit = editNone
+}
+
+// SetWidth rewraps the text at a new width, keeping the cursor in view
+func (t *textArea) SetWidth(width int) {
+	t.width = width
+	t.goal = -1
+	t.scrollToCursor()
+}
+
+func (t textArea) Value() string {
+	return string(t.runes)
+}
+
+// Len is the length of the text in runes
+func (t textArea) Len() int {
+	return len(t.runes)
+}
+
+// Update handles a key press. It reports whether the key was used, so the
+// caller can give unhandled keys (tab, ctrl+s...) their own meaning.
+func (t *textArea) Update(msg tea.KeyMsg) bool {
+	key := msg.String()
+
+	// Vertical moves remember the column they started from
+	switch key {
+	case "up", "down", "pgup", "pgdown", "shift+up", "shift+down":
+	default:
+		t.goal = -1
+	}
+
+	switch key {
+	// Movement, with shift extending the selection
+	case "left", "shift+left":
+		t.move(key, t.cursor-1)
+	case "right", "shift+right":
+		t.move(key, t.cursor+1)
+	case "ctrl+left", "alt+left", "alt+b", "ctrl+shift+left":
+		t.move(key, t.wordLeft())
+	case "ctrl+right", "alt+right", "alt+f", "ctrl+shift+right":
+		t.move(key, t.wordRight())
+	case "up", "shift+up":
+		t.move(key, t.verticalTarget(-1))
+	case "down", "shift+down":
+		t.move(key, t.verticalTarget(1))
+	case "pgup":
+		t.move(key, t.verticalTarget(-t.height))
+	case "pgdown":
+		t.move(key, t.verticalTarget(t.height))
+	case "home", "shift+home":
+		lines := t.lines()
+		t.move(key, lines[t.cursorLine(lines)].start)
+	case "end", "shift+end":
+		lines := t.lines()
+		t.move(key, lines[t.cursorLine(lines)].end)
+	case "ctrl+home", "ctrl+shift+home":
+		t.move(key, 0)
+	case "ctrl+end", "ctrl+shift+end":
+		t.move(key, len(t.runes))
+	case "ctrl+a":
+		t.anchor = 0
+		t.cursor = len(t.runes)
+
+	// Editing
+	case "enter":
+		t.insert([]rune{'\n'})
+	case "backspace":
+		if !t.deleteSelection() && t.cursor > 0 {
+			t.snapshot(editDelete)
+			t.runes = append(t.runes[:t.cursor-1], t.runes[t.cursor:]...)
+			t.cursor--
+		}
+	case "delete":
+		if !t.deleteSelection() && t.cursor < len(t.runes) {
+			t.snapshot(editDelete)
+			t.runes = append(t.runes[:t.cursor], t.runes[t.cursor+1:]...)
+		}
+	case "ctrl+w":
+		if !t.deleteSelection() {
+			t.anchor = t.wordLeft()
+			t.deleteSelection()
+		}
+	case "ctrl+z":
+		t.undoEdit()
+	case "ctrl+y":
+		t.redoEdit()
+
+	default:
+		if msg.Type != tea.KeyRunes && msg.Type != tea.KeySpace {
+			return false
+		}
+		if msg.Alt {
+			return false
+		}
+		t.insert(msg.Runes)
+	}
+
+	t.scrollToCursor()
+	return true
+}
+
+// move places the cursor at target. Keys with shift extend the selection,
+// all others drop it.
+func (t *textArea) move(key string, target int) {
+	if strings.Contains(key, "shift+") {
+		if t.anchor < 0 {
+			t.anchor = t.cursor
+		}
+	} else {
+		t.anchor = -1
+	}
+
+	if target < 0 {
+		target = 0
+	}
+	if target > len(t.runes) {
+		target = len(t.runes)
+	}
+	t.cursor = target
+	t.lastEdit = editNone
+}
+
+// selection returns the selected range, ok is false when nothing is selected
+func (t textArea) selection() (start, end int, ok bool) {
+	if t.anchor < 0 || t.anchor == t.cursor {
+		return 0, 0, false
+	}
+	if t.anchor < t.cursor {
+		return t.anchor, t.cursor, true
+	}
+	return t.cursor, t.anchor, true
+}
+
+func (t *textArea) deleteSelection() bool {
+	start, end, ok := t.selection()
+	t.anchor = -1
+	if !ok {
+		return false
+	}
+
+	t.snapshot(editOther)
+	t.runes = append(t.runes[:start], t.runes[end:]...)
+	t.cursor = start
+	return true
+}
+
+// insert puts runes at the cursor, replacing the selection
+func (t *textArea) insert(runes []rune) {
+	if _, _, ok := t.selection(); ok {
+		t.deleteSelection()
+		t.lastEdit = editOther
+	}
+
+	if t.limit > 0 && len(t.runes)+len(runes) > t.limit {
+		runes = runes[:max(0, t.limit-len(t.runes))]
+	}
+	if len(runes) == 0 {
+		return
+	}
+
+	kind := editInsert
+	if len(runes) > 1 || runes[0] == '\n' {
+		kind = editOther // pastes and new lines are undone on their own
+	}
+	t.snapshot(kind)
+
+	updated := make([]rune, 0, len(t.runes)+len(runes))
+	updated = append(updated, t.runes[:t.cursor]...)
+	updated = append(updated, runes...)
+	updated = append(updated, t.runes[t.cursor:]...)
+	t.runes = updated
+	t.cursor += len(runes)
+}
+
+// snapshot records the state before an edit for undo
+func (t *textArea) snapshot(kind editKind) {
+	if kind != editOther && kind == t.lastEdit {
+		return
+	}
+	t.lastEdit = kind
+
+	t.undo = append(t.undo, textSnapshot{text: string(t.runes), cursor: t.cursor})
+	if len(t.undo) > maxUndo {
+		t.undo = t.undo[len(t.undo)-maxUndo:]
+	}
+	t.redo = nil
+}
+
+func (t *textArea) undoEdit() {
+	if len(t.undo) == 0 {
+		return
+	}
+	t.redo = append(t.redo, textSnapshot{text: string(t.runes), cursor: t.cursor})
+	t.restore(t.undo[len(t.undo)-1])
+	t.undo = t.undo[:len(t.undo)-1]
+}
+
+func (t *textArea) redoEdit() {
+	if len(t.redo) == 0 {
+		return
+	}
+	t.undo = append(t.undo, textSnapshot{text: string(t.runes), cursor: t.cursor})
+	t.restore(t.redo[len(t.redo)-1])
+	t.redo = t.redo[:len(t.redo)-1]
+}
+
+func (t *textArea) restore(s textSnapshot) {
+	t.runes = []rune(s.text)
+	t.cursor = s.cursor
+	t.anchor = -1
+	t.lastEdit = editNone
+}
+
+func (t textArea) wordLeft() int {
+	i := t.cursor
+	for i > 0 && !isWordRune(t.runes[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(t.runes[i-1]) {
+		i--
+	}
+	return i
+}
+
+func (t textArea) wordRight() int {
+	i := t.cursor
+	for i < len(t.runes) && !isWordRune(t.runes[i]) {
+		i++
+	}
+	for i < len(t.runes) && isWordRune(t.runes[i]) {
+		i++
+	}
+	return i
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// lines soft-wraps the text, breaking after spaces where possible. There
+// is always at least one line.
+func (t textArea) lines() []visualLine {
+	var lines []visualLine
+	width := max(t.width, 1)
+
+	for start := 0; start <= len(t.runes); {
+		end := start
+		for end < len(t.runes) && t.runes[end] != '\n' {
+			end++
+		}
+
+		lineStart := start
+		for {
+			cells, i, lastSpace := 0, lineStart, -1
+			for i < end {
+				w := runewidth.RuneWidth(t.runes[i])
+				if cells+w > width && i > lineStart {
+					break
+				}
+				cells += w
+				if t.runes[i] == ' ' {
+					lastSpace = i
+				}
+				i++
+			}
+			if i >= end {
+				lines = append(lines, visualLine{lineStart, end})
+				break
+			}
+			if t.runes[i] == ' ' {
+				// Break on the space itself; it isn't drawn on either line
+				lines = append(lines, visualLine{lineStart, i})
+				lineStart = i + 1
+				continue
+			}
+			if lastSpace >= lineStart {
+				i = lastSpace + 1
+			}
+			lines = append(lines, visualLine{lineStart, i})
+			lineStart = i
+		}
+
+		start = end + 1
+	}
+
+	return lines
+}
+
+// cursorLine finds the wrapped line holding the cursor. At a soft wrap the
+// cursor belongs to the start of the next line.
+func (t textArea) cursorLine(lines []visualLine) int {
+	for i, line := range lines {
+		if t.cursor < line.start || t.cursor > line.end {
+			continue
+		}
+		if t.cursor == line.end && i+1 < len(lines) && lines[i+1].start == t.cursor {
+			continue
+		}
+		return i
+	}
+	return len(lines) - 1
+}
+
+func (t textArea) column(line visualLine, offset int) int {
+	return runewidth.StringWidth(string(t.runes[line.start:offset]))
+}
+
+// verticalTarget is the offset delta lines above or below the cursor, as
+// close as possible to the column the vertical movement started in
+func (t *textArea) verticalTarget(delta int) int {
+	lines := t.lines()
+	row := t.cursorLine(lines)
+	if t.goal < 0 {
+		t.goal = t.column(lines[row], t.cursor)
+	}
+
+	row += delta
+	if row < 0 {
+		return 0
+	}
+	if row >= len(lines) {
+		return len(t.runes)
+	}
+
+	line := lines[row]
+	cells := 0
+	for i := line.start; i < line.end; i++ {
+		w := runewidth.RuneWidth(t.runes[i])
+		if cells+w > t.goal {
+			return i
+		}
+		cells += w
+	}
+	return line.end
+}
+
+func (t *textArea) scrollToCursor() {
+	row := t.cursorLine(t.lines())
+	if row < t.offset {
+		t.offset = row
+	}
+	if row >= t.offset+t.height {
+		t.offset = row - t.height + 1
+	}
+}
+
+var selectionStyle = lipgloss.NewStyle().
+	Foreground(bg).
+	Background(accent)
+
+// View renders the visible lines. cursor is drawn at the cursor position
+// when the area is focused.
+func (t textArea) View(focused bool, cursor string) string {
+	lines := t.lines()
+	selStart, selEnd, hasSelection := t.selection()
+
+	offset := t.offset
+	if offset > len(lines)-1 {
+		offset = max(0, len(lines)-1)
+	}
+	last := min(len(lines), offset+t.height)
+	cursorRow := t.cursorLine(lines)
+
+	rendered := make([]string, 0, t.height)
+	for row := offset; row < last; row++ {
+		line := lines[row]
+		var b strings.Builder
+		for i := line.start; i < line.end; i++ {
+			if focused && row == cursorRow && i == t.cursor {
+				b.WriteString(cursor)
+			}
+			if hasSelection && i >= selStart && i < selEnd {
+				b.WriteString(selectionStyle.Render(string(t.runes[i])))
+			} else {
+				b.WriteRune(t.runes[i])
+			}
+		}
+		if focused && row == cursorRow && t.cursor == line.end {
+			b.WriteString(cursor)
+		}
+		rendered = append(rendered, b.String())
+	}
+
+	return strings.Join(rendered, "\n")
+}
+
+// Position describes the cursor as line:column of the unwrapped text
+func (t textArea) Position() (line, column int) {
+	line = 1
+	lineStart := 0
+	for i := 0; i < t.cursor; i++ {
+		if t.runes[i] == '\n' {
+			line++
+			lineStart = i + 1
+		}
+	}
+	return line, t.cursor - lineStart + 1
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var testKeys = map[string]tea.KeyType{
+	"left": tea.KeyLeft, "right": tea.KeyRight, "up": tea.KeyUp, "down": tea.KeyDown,
+	"shift+left": tea.KeyShiftLeft, "home": tea.KeyHome, "end": tea.KeyEnd,
+	"enter": tea.KeyEnter, "backspace": tea.KeyBackspace, "delete": tea.KeyDelete,
+	"ctrl+w": tea.KeyCtrlW, "ctrl+z": tea.KeyCtrlZ, "ctrl+y": tea.KeyCtrlY, "ctrl+a": tea.KeyCtrlA,
+}
+
+// keyMsg turns a key name, or text to type, into a key press
+func keyMsg(key string) tea.KeyMsg {
+	if keyType, ok := testKeys[key]; ok {
+		return tea.KeyMsg{Type: keyType}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+func TestTextArea(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		limit      int
+		keys       []string
+		want       string
+		wantCursor int
+	}{
+		{"typing and backspace", "", 0, []string{"z", "a", "ż", "backspace"}, "za", 2},
+		{"backspace removes a whole rune", "gęślą", 0, []string{"left", "left", "backspace"}, "gęlą", 2},
+		{"delete", "żółw", 0, []string{"home", "delete"}, "ółw", 0},
+		{"typing replaces the selection", "abc", 0, []string{"shift+left", "shift+left", "X"}, "aX", 2},
+		{"select all", "abc", 0, []string{"ctrl+a", "backspace"}, "", 0},
+		{"delete a word", "hello wörld", 0, []string{"ctrl+w"}, "hello ", 6},
+		{"undo a typed word", "x", 0, []string{"a", "b", "ctrl+z"}, "x", 1},
+		{"redo", "x", 0, []string{"a", "b", "ctrl+z", "ctrl+y"}, "xab", 3},
+		{"new lines undo on their own", "", 0, []string{"a", "enter", "b", "ctrl+z", "ctrl+z"}, "a", 1},
+		{"limit cuts a paste", "", 3, []string{"abcd", "e"}, "abc", 3},
+		{"limit counts runes", "", 3, []string{"żżż", "ż"}, "żżż", 3},
+		{"up keeps the column", "abcdef\nab\nabcdef", 0, []string{"up", "up"}, "abcdef\nab\nabcdef", 6},
+		{"down to a shorter line", "abcdef\nab", 0, []string{"ctrl+a", "left", "end", "down"}, "abcdef\nab", 9},
+	}
+
+	for _, tt := range tests {
+		area := newTextArea(40, 5, tt.limit)
+		area.SetValue(tt.value)
+		for _, key := range tt.keys {
+			area.Update(keyMsg(key))
+		}
+		if area.Value() != tt.want || area.cursor != tt.wantCursor {
+			t.Errorf("%s: %q with the cursor at %d, want %q at %d", tt.name, area.Value(), area.cursor, tt.want, tt.wantCursor)
+		}
+	}
+}
+
+func TestTextAreaLines(t *testing.T) {
+	tests := []struct {
+		value string
+		width int
+		want  []visualLine
+	}{
+		{"", 5, []visualLine{{0, 0}}},
+		{"ab\ncd", 5, []visualLine{{0, 2}, {3, 5}}},
+		// The space a line breaks at stays on its end
+		{"aaa bbb ccc", 5, []visualLine{{0, 4}, {4, 8}, {8, 11}}},
+		{"abcdefgh", 5, []visualLine{{0, 5}, {5, 8}}},
+		// Wide characters take two cells each
+		{"日本語日本", 4, []visualLine{{0, 2}, {2, 4}, {4, 5}}},
+	}
+	for _, tt := range tests {
+		area := newTextArea(tt.width, 5, 0)
+		area.SetValue(tt.value)
+		if got := area.lines(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lines of %q at width %d = %v, want %v", tt.value, tt.width, got, tt.want)
+		}
+	}
+
+	area := newTextArea(40, 5, 0)
+	area.SetValue("ab\nżółw")
+	if line, column := area.Position(); line != 2 || column != 5 {
+		t.Errorf("Position = %d:%d, want 2:5", line, column)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// trashRetentions are the settings choices for how long deleted notes stay
+// in the trash; 0 keeps them until purged by hand
+var trashRetentions = []time.Duration{
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+	90 * 24 * time.Hour,
+	0,
+}
+
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// DeleteNote moves a note to the trash. It can be brought back with
+// RestoreNote until it is purged.
+func (n *Notebook) DeleteNote(id string) {
+	index := n.indexOf(id)
+	if index < 0 {
+		return
+	}
+
+	note := n.Notes[index]
+	deleted := time.Now()
+	note.Deleted = &deleted
+	n.Notes = append(n.Notes[:index], n.Notes[index+1:]...)
+	n.Trash = append(n.Trash, note)
+	n.reindex(id)
+	n.markDirty()
+}
+
+// RestoreNote moves a note from the trash back into the notebook
+func (n *Notebook) RestoreNote(id string) bool {
+	index := n.trashIndexOf(id)
+	if index < 0 {
+		return false
+	}
+
+	note := n.Trash[index]
+	note.Deleted = nil
+	n.Trash = append(n.Trash[:index], n.Trash[index+1:]...)
+	n.Notes = append(n.Notes, note)
+	n.reindex(id)
+	n.markDirty()
+	return true
+}
+
+// PurgeNote deletes a note from the trash for good
+func (n *Notebook) PurgeNote(id string) bool {
+	index := n.trashIndexOf(id)
+	if index < 0 {
+		return false
+	}
+
+	n.Trash = append(n.Trash[:index], n.Trash[index+1:]...)
+	n.markDirty()
+	return true
+}
+
+// EmptyTrash purges every note in the trash
+func (n *Notebook) EmptyTrash() int {
+	count := len(n.Trash)
+	if count > 0 {
+		n.Trash = nil
+		n.markDirty()
+	}
+	return count
+}
+
+// PurgeExpired purges notes that have been in the trash longer than the
+// notebook's trash retention. Only a retention read from the file counts:
+// older files keep their trash until the setting has been saved once.
+func (n *Notebook) PurgeExpired(now time.Time) int {
+	if !n.settingsLoaded || n.trashRetention == 0 {
+		return 0
+	}
+
+	kept := n.Trash[:0]
+	purged := 0
+	for _, note := range n.Trash {
+		if note.Deleted != nil && now.Sub(*note.Deleted) > n.trashRetention {
+			purged++
+			continue
+		}
+		kept = append(kept, note)
+	}
+	n.Trash = kept
+
+	if purged > 0 {
+		n.markDirty()
+	}
+	return purged
+}
+
+// SetTrashRetention changes how long deleted notes are kept. It is saved
+// with the notebook.
+func (n *Notebook) SetTrashRetention(retention time.Duration) {
+	n.trashRetention = retention
+	n.markDirty()
+}
+
+func (n *Notebook) trashIndexOf(id string) int {
+	for i := range n.Trash {
+		if n.Trash[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func trashRetentionText(retention time.Duration) string {
+	if retention == 0 {
+		return "Keep until emptied"
+	}
+	return fmt.Sprintf("Purge after %d days", int(retention.Hours()/24))
+}
+
+func nextTrashRetention(current time.Duration) time.Duration {
+	for i, retention := range trashRetentions {
+		if retention == current {
+			return trashRetentions[(i+1)%len(trashRetentions)]
+		}
+	}
+	return trashRetentions[0]
+}
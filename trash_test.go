@@ -0,0 +1,91 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPurgeExpired(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *time.Time {
+		deleted := now.Add(-d)
+		return &deleted
+	}
+	week := 7 * 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		retention time.Duration
+		saved     bool // the retention was read from the file
+		deleted   []*time.Time
+		want      int // notes left in the trash
+	}{
+		{"expired", week, true, []*time.Time{ago(8 * 24 * time.Hour), ago(time.Hour)}, 1},
+		{"exactly the retention is kept", week, true, []*time.Time{ago(week)}, 1},
+		{"no deletion time is kept", week, true, []*time.Time{nil}, 1},
+		{"retention off", 0, true, []*time.Time{ago(365 * 24 * time.Hour)}, 1},
+		{"retention not saved yet", week, false, []*time.Time{ago(365 * 24 * time.Hour)}, 1},
+	}
+
+	for _, tt := range tests {
+		notebook := NewNotebook("", "")
+		notebook.trashRetention = tt.retention
+		notebook.settingsLoaded = tt.saved
+		for _, deleted := range tt.deleted {
+			notebook.Trash = append(notebook.Trash, Note{ID: newNoteID(), Deleted: deleted})
+		}
+
+		purged := notebook.PurgeExpired(now)
+		if len(notebook.Trash) != tt.want || purged != len(tt.deleted)-tt.want {
+			t.Errorf("%s: purged %d, %d left, want %d left", tt.name, purged, len(notebook.Trash), tt.want)
+		}
+		if notebook.IsDirty() != (purged > 0) {
+			t.Errorf("%s: dirty = %v after purging %d", tt.name, notebook.IsDirty(), purged)
+		}
+	}
+}
+
+func TestTrash(t *testing.T) {
+	notebook := NewNotebook(filepath.Join(t.TempDir(), "notes.alpaka"), "secret")
+	keep, purge := NewNote("Keep", "", nil), NewNote("Purge", "", nil)
+	notebook.AddNote(keep)
+	notebook.AddNote(purge)
+
+	notebook.DeleteNote(keep.ID)
+	notebook.DeleteNote(purge.ID)
+	if len(notebook.Notes) != 0 || len(notebook.Trash) != 2 || notebook.Trash[0].Deleted == nil {
+		t.Fatalf("after deleting: %d notes, trash %+v", len(notebook.Notes), notebook.Trash)
+	}
+	if results, _ := notebook.Search("keep"); len(results) != 0 {
+		t.Error("search finds a note in the trash")
+	}
+
+	if !notebook.RestoreNote(keep.ID) || notebook.RestoreNote(keep.ID) {
+		t.Error("RestoreNote should succeed once")
+	}
+	if note, ok := notebook.GetNote(keep.ID); !ok || note.Deleted != nil {
+		t.Errorf("restored note = %+v, %v", note, ok)
+	}
+	if !notebook.PurgeNote(purge.ID) || len(notebook.Trash) != 0 {
+		t.Error("PurgeNote didn't empty the trash")
+	}
+
+	// The retention setting survives a reload
+	week := 7 * 24 * time.Hour
+	notebook.DeleteNote(keep.ID)
+	notebook.SetTrashRetention(week)
+	if err := notebook.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNotebook(notebook.filename, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.trashRetention != week || !loaded.settingsLoaded || len(loaded.Trash) != 1 {
+		t.Errorf("reloaded retention %v, saved %v, %d in the trash", loaded.trashRetention, loaded.settingsLoaded, len(loaded.Trash))
+	}
+	if loaded.EmptyTrash() != 1 || len(loaded.Trash) != 0 {
+		t.Error("EmptyTrash didn't empty the trash")
+	}
+}
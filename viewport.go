@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Screens taller than the terminal show a window of their content lines.
+// scrollOffset is the first visible line and maxScroll the last valid
+// offset for the content that was measured last.
+
+const minViewportHeight = 5
+
+// viewportHeight is how many content lines fit between the given header
+// and footer. 0 means the terminal size isn't known yet, show everything.
+func (m model) viewportHeight(header, footer string) int {
+	if m.height == 0 {
+		return 0
+	}
+	height := m.height - screenLines(header, m.width) - screenLines(footer, m.width) - 1
+	return max(height, minViewportHeight)
+}
+
+// screenLines counts the terminal rows s takes, including lines the
+// terminal wraps because they are wider than it
+func screenLines(s string, width int) int {
+	rows := 0
+	for _, line := range strings.Split(s, "\n") {
+		w := lipgloss.Width(line)
+		if width > 0 && w > width {
+			rows += (w + width - 1) / width
+		} else {
+			rows++
+		}
+	}
+	return rows
+}
+
+// clampScroll keeps scrollOffset within the content
+func (m model) clampScroll(total, height int) model {
+	m.maxScroll = 0
+	if height > 0 && total > height {
+		m.maxScroll = total - height
+	}
+	if m.scrollOffset > m.maxScroll {
+		m.scrollOffset = m.maxScroll
+	}
+	if m.scrollOffset < 0 {
+		m.scrollOffset = 0
+	}
+	return m
+}
+
+// scrollTo makes the lines top..bottom visible, preferring the top when
+// they don't fit
+func (m model) scrollTo(top, bottom, total, height int) model {
+	if height > 0 {
+		if bottom >= m.scrollOffset+height {
+			m.scrollOffset = bottom - height + 1
+		}
+		if top < m.scrollOffset {
+			m.scrollOffset = top
+		}
+	}
+	return m.clampScroll(total, height)
+}
+
+// renderViewport shows height lines starting at offset with a scrollbar,
+// or every line if they fit
+func renderViewport(lines []string, offset, height int) string {
+	if height <= 0 || len(lines) <= height {
+		return strings.Join(lines, "\n")
+	}
+
+	offset = max(0, min(offset, len(lines)-height))
+	body := strings.Join(lines[offset:offset+height], "\n")
+
+	// Thumb size and position proportional to the visible part
+	thumb := max(1, height*height/len(lines))
+	position := offset * (height - thumb) / (len(lines) - height)
+
+	track := lipgloss.NewStyle().Foreground(muted)
+	handle := lipgloss.NewStyle().Foreground(primary)
+	bar := make([]string, height)
+	for i := range bar {
+		if i >= position && i < position+thumb {
+			bar[i] = handle.Render("┃")
+		} else {
+			bar[i] = track.Render("│")
+		}
+	}
+
+	view := lipgloss.JoinHorizontal(lipgloss.Top, body, " ", strings.Join(bar, "\n"))
+	indicator := noteMetaStyle.Render(fmt.Sprintf("lines %d–%d of %d (%d%%)",
+		offset+1, offset+height, len(lines), (offset+height)*100/len(lines)))
+	return view + "\n" + indicator
+}